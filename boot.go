@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Maximum reboot events kept for /dishy/reboots
+const rebootLogSize = 100
+
+// A reboot observed by the exporter
+type rebootEvent struct {
+	Observed  time.Time        `json:"observed"`
+	Bootcount int32            `json:"bootcount"`
+	Reason    string           `json:"reason"`
+	Reasons   map[string]int32 `json:"reasons,omitempty"` // Boots by reason since the previous poll
+}
+
+// Reboot events of the primary dish, oldest first
+var (
+	rebootLog     []rebootEvent
+	rebootLogLock sync.RWMutex
+)
+
+// Exports boot counts by reason and records reboots since the last poll
+func (d *dishDevice) updateBootMetrics(info *starlink.DeviceInfo) {
+	boot := info.GetBoot()
+	if boot == nil {
+		return
+	}
+
+	// Lifetime Counts
+	for value, name := range starlink.BootReason_name {
+		d.promDishyBootsByReason.WithLabelValues(name).Set(float64(boot.GetCountByReason()[value]))
+	}
+	setStateSet(d.promDishyLastBootReason, starlink.BootReason_name, int32(boot.GetLastReason()))
+
+	// Reboots
+	current := info.GetBootcount()
+	if d.bootcountKnown && current > d.bootcountLast {
+		d.recordReboot(info, current-d.bootcountLast, d.bootReasonsLast)
+	}
+	d.bootcountLast = current
+	d.bootcountKnown = true
+	d.bootReasonsLast = make(map[int32]int32, len(boot.GetCountByReason()))
+	for value, count := range boot.GetCountByReason() {
+		d.bootReasonsLast[value] = count
+	}
+}
+
+// Counts and logs reboots seen between polls, attributed by comparing lifetime
+// counts by reason with the previous poll's
+func (d *dishDevice) recordReboot(info *starlink.DeviceInfo, boots int32, previous map[int32]int32) {
+	boot := info.GetBoot()
+	event := rebootEvent{
+		Observed:  time.Now(),
+		Bootcount: info.GetBootcount(),
+		Reason:    boot.GetLastReason().String(),
+	}
+
+	// Fall back to the last reason if no reason's count went up
+	for value, count := range boot.GetCountByReason() {
+		if count <= previous[value] {
+			continue
+		}
+		if event.Reasons == nil {
+			event.Reasons = make(map[string]int32)
+		}
+		name := starlink.BootReason(value).String()
+		event.Reasons[name] = count - previous[value]
+		d.promDishyReboots.WithLabelValues(name).Add(float64(count - previous[value]))
+	}
+	if event.Reasons == nil {
+		d.promDishyReboots.WithLabelValues(event.Reason).Add(float64(boots))
+	}
+
+	log.WithFields(logrus.Fields{
+		"Bootcount": event.Bootcount,
+		"Boots":     boots,
+		"Reason":    event.Reason,
+	}).Warn("Dishy rebooted")
+
+	if !d.primary {
+		return
+	}
+	rebootLogLock.Lock()
+	rebootLog = append(rebootLog, event)
+	if len(rebootLog) > rebootLogSize {
+		rebootLog = rebootLog[len(rebootLog)-rebootLogSize:]
+	}
+	rebootLogLock.Unlock()
+}
+
+// Serves observed reboot events as JSON
+func serveReboots(w http.ResponseWriter, r *http.Request) {
+	rebootLogLock.RLock()
+	events := make([]rebootEvent, len(rebootLog))
+	copy(events, rebootLog)
+	rebootLogLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(events); err != nil {
+		log.WithField("Error", err).Debug("Failed to write reboot log")
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Starlink device GRPC client, each call builds its own request so calls may run concurrently
+type deviceClient struct {
+	device  starlink.DeviceClient
+	name    string // Device name for logs
+	metrics requestMetrics
+}
+
+// Metrics recorded for every request to a device
+type requestMetrics struct {
+	grpcTime prometheus.Histogram
+	failing  prometheus.Gauge
+	failures prometheus.Counter
+	requests prometheus.Counter
+}
+
+func newDishClient(conn grpc.ClientConnInterface, m *dishMetricSet) *deviceClient {
+	return &deviceClient{
+		device: starlink.NewDeviceClient(conn),
+		name:   "Dishy",
+		metrics: requestMetrics{
+			grpcTime: m.promDishyGRPCTime,
+			failing:  m.promDishyFailing,
+			failures: m.promDishyFailures,
+			requests: m.promDishyRequests,
+		},
+	}
+}
+
+func newRouterClient(conn grpc.ClientConnInterface, m *routerMetricSet) *deviceClient {
+	return &deviceClient{
+		device: starlink.NewDeviceClient(conn),
+		name:   "Router",
+		metrics: requestMetrics{
+			grpcTime: m.promRouterGRPCTime,
+			failing:  m.promRouterFailing,
+			failures: m.promRouterFailures,
+			requests: m.promRouterRequests,
+		},
+	}
+}
+
+// Requests DeviceInfo
+func (c *deviceClient) GetDeviceInfo(ctx context.Context) (*starlink.DeviceInfo, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetDeviceInfo{GetDeviceInfo: &starlink.GetDeviceInfoRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetGetDeviceInfo().GetDeviceInfo() == nil {
+		return nil, errUnexpectedResponse("GetDeviceInfo")
+	}
+	return resp.GetGetDeviceInfo().GetDeviceInfo(), nil
+}
+
+// Requests Dishy status
+func (c *deviceClient) GetStatus(ctx context.Context) (*starlink.DishGetStatusResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetStatus{GetStatus: &starlink.GetStatusRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetDishGetStatus() == nil {
+		return nil, errUnexpectedResponse("GetStatus")
+	}
+	return resp.GetDishGetStatus(), nil
+}
+
+// Requests Dishy history ring buffers
+func (c *deviceClient) GetHistory(ctx context.Context) (*starlink.DishGetHistoryResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetHistory{GetHistory: &starlink.GetHistoryRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetDishGetHistory() == nil {
+		return nil, errUnexpectedResponse("GetHistory")
+	}
+	return resp.GetDishGetHistory(), nil
+}
+
+// Requests Dishy context, cell and PoP assignment
+func (c *deviceClient) GetContext(ctx context.Context) (*starlink.DishGetContextResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_DishGetContext{DishGetContext: &starlink.DishGetContextRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetDishGetContext() == nil {
+		return nil, errUnexpectedResponse("DishGetContext")
+	}
+	return resp.GetDishGetContext(), nil
+}
+
+// Requests the Dishy GPS location
+func (c *deviceClient) GetLocation(ctx context.Context) (*starlink.GetLocationResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetLocation{GetLocation: &starlink.GetLocationRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetGetLocation() == nil {
+		return nil, errUnexpectedResponse("GetLocation")
+	}
+	return resp.GetGetLocation(), nil
+}
+
+// Starts a Dishy speed test, poll GetSpeedtestStatus for the result
+func (c *deviceClient) StartSpeedtest(ctx context.Context) error {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_StartSpeedtest{StartSpeedtest: &starlink.StartSpeedtestRequest{}},
+	})
+	if err != nil {
+		return err
+	}
+	if resp.GetStartSpeedtest() == nil {
+		return errUnexpectedResponse("StartSpeedtest")
+	}
+	return nil
+}
+
+// Runs a legacy Dishy speed test, blocking until it completes
+func (c *deviceClient) SpeedTest(ctx context.Context) (*starlink.SpeedTestResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_SpeedTest{SpeedTest: &starlink.SpeedTestRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetSpeedTest() == nil {
+		return nil, errUnexpectedResponse("SpeedTest")
+	}
+	return resp.GetSpeedTest(), nil
+}
+
+// Requests the status of the latest Dishy speed test
+func (c *deviceClient) GetSpeedtestStatus(ctx context.Context) (*starlink.SpeedtestStatus, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetSpeedtestStatus{GetSpeedtestStatus: &starlink.GetSpeedtestStatusRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetGetSpeedtestStatus() == nil {
+		return nil, errUnexpectedResponse("GetSpeedtestStatus")
+	}
+	return resp.GetGetSpeedtestStatus().GetStatus(), nil
+}
+
+// Requests the Dishy obstruction map
+func (c *deviceClient) GetObstructionMap(ctx context.Context) (*starlink.DishGetObstructionMapResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_DishGetObstructionMap{DishGetObstructionMap: &starlink.DishGetObstructionMapRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetDishGetObstructionMap() == nil {
+		return nil, errUnexpectedResponse("DishGetObstructionMap")
+	}
+	return resp.GetDishGetObstructionMap(), nil
+}
+
+// Requests transceiver status
+func (c *deviceClient) GetTransceiverStatus(ctx context.Context) (*starlink.TransceiverGetStatusResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_TransceiverGetStatus{TransceiverGetStatus: &starlink.TransceiverGetStatusRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetTransceiverGetStatus() == nil {
+		return nil, errUnexpectedResponse("TransceiverGetStatus")
+	}
+	return resp.GetTransceiverGetStatus(), nil
+}
+
+// Requests transceiver RF telemetry
+func (c *deviceClient) GetTransceiverTelemetry(ctx context.Context) (*starlink.TransceiverGetTelemetryResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_TransceiverGetTelemetry{TransceiverGetTelemetry: &starlink.TransceiverGetTelemetryRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetTransceiverGetTelemetry() == nil {
+		return nil, errUnexpectedResponse("TransceiverGetTelemetry")
+	}
+	return resp.GetTransceiverGetTelemetry(), nil
+}
+
+// Requests router status
+func (c *deviceClient) GetWifiStatus(ctx context.Context) (*starlink.WifiGetStatusResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetStatus{GetStatus: &starlink.GetStatusRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetStatus() == nil {
+		return nil, errUnexpectedResponse("GetStatus")
+	}
+	return resp.GetWifiGetStatus(), nil
+}
+
+// Requests clients connected to the router
+func (c *deviceClient) GetWifiClients(ctx context.Context) (*starlink.WifiGetClientsResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_WifiGetClients{WifiGetClients: &starlink.WifiGetClientsRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetClients() == nil {
+		return nil, errUnexpectedResponse("WifiGetClients")
+	}
+	return resp.GetWifiGetClients(), nil
+}
+
+// Requests router internet ping metrics
+func (c *deviceClient) GetWifiPingMetrics(ctx context.Context) (*starlink.WifiGetPingMetricsResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_WifiGetPingMetrics{WifiGetPingMetrics: &starlink.WifiGetPingMetricsRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetPingMetrics() == nil {
+		return nil, errUnexpectedResponse("WifiGetPingMetrics")
+	}
+	return resp.GetWifiGetPingMetrics(), nil
+}
+
+// Requests router history ring buffers
+func (c *deviceClient) GetWifiHistory(ctx context.Context) (*starlink.WifiGetHistoryResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_GetHistory{GetHistory: &starlink.GetHistoryRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetHistory() == nil {
+		return nil, errUnexpectedResponse("GetHistory")
+	}
+	return resp.GetWifiGetHistory(), nil
+}
+
+// Requests per-second history for one router client
+func (c *deviceClient) GetWifiClientHistory(ctx context.Context, mac string) (*starlink.WifiGetClientHistoryResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_WifiGetClientHistory{WifiGetClientHistory: &starlink.WifiGetClientHistoryRequest{MacAddress: mac}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetClientHistory() == nil {
+		return nil, errUnexpectedResponse("WifiGetClientHistory")
+	}
+	return resp.GetWifiGetClientHistory(), nil
+}
+
+// Requests router diagnostics including a neighbor scan
+func (c *deviceClient) GetWifiDiagnostics(ctx context.Context) (*starlink.WifiGetDiagnosticsResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_WifiGetDiagnostics{WifiGetDiagnostics: &starlink.WifiGetDiagnosticsRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetWifiGetDiagnostics() == nil {
+		return nil, errUnexpectedResponse("WifiGetDiagnostics")
+	}
+	return resp.GetWifiGetDiagnostics(), nil
+}
+
+// Requests the Dishy configuration
+func (c *deviceClient) GetConfig(ctx context.Context) (*starlink.DishGetConfigResponse, error) {
+	resp, err := c.handle(ctx, &starlink.Request{
+		Request: &starlink.Request_DishGetConfig{DishGetConfig: &starlink.DishGetConfigRequest{}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.GetDishGetConfig() == nil {
+		return nil, errUnexpectedResponse("DishGetConfig")
+	}
+	return resp.GetDishGetConfig(), nil
+}
+
+// Generic request handler, records request metrics
+func (c *deviceClient) handle(ctx context.Context, req *starlink.Request) (*starlink.Response, error) {
+	// Prepare request context, callers with their own deadline may wait longer
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout*time.Second)
+		defer cancel()
+	}
+
+	t1 := time.Now()
+	resp, err := c.device.Handle(ctx, req) // Make request
+
+	c.metrics.grpcTime.Observe(float64(time.Now().Sub(t1).Milliseconds()))
+
+	if isUnsupported(err) {
+		// Device is up but firmware rejects this request, not a failure
+		log.WithFields(logrus.Fields{
+			"Request": req.Request,
+			"Error":   err,
+		}).Debug("Request not supported by device")
+	} else if err != nil {
+		c.metrics.failing.Set(1)
+		c.metrics.failures.Inc()
+		log.WithFields(logrus.Fields{
+			"Request": req.Request,
+			"Error":   err,
+		}).Errorf("Unable to request data from %s", c.name)
+	} else {
+		c.metrics.failing.Set(0)
+	}
+
+	c.metrics.requests.Inc()
+
+	return resp, err
+}
+
+// Reports whether the device rejected a request it doesn't support
+func isUnsupported(err error) bool {
+	switch status.Code(err) {
+	case codes.Unimplemented, codes.PermissionDenied:
+		return true
+	}
+	return false
+}
+
+// Error for a response missing the expected message
+func errUnexpectedResponse(request string) error {
+	return fmt.Errorf("unexpected response to %s request", request)
+}
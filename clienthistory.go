@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"strings"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Router client MACs to collect per-second history for, comma separated
+var routerClientHistory string
+
+func init() {
+	flag.StringVar(&routerClientHistory, "routerClientHistory", routerClientHistory, "Comma separated router client MAC addresses to collect per-second throughput history for")
+}
+
+// Returns configured client MACs for history collection
+func clientHistoryMACs() []string {
+	var macs []string
+	for _, mac := range strings.Split(routerClientHistory, ",") {
+		if mac = normalizeMAC(mac); mac != "" {
+			macs = append(macs, mac)
+		}
+	}
+	return macs
+}
+
+// Requests history for each configured client and ingests new samples
+func (r *routerDevice) updateClientHistoryMetrics(ctx context.Context) {
+	for _, mac := range clientHistoryMACs() {
+		history, err := r.client.GetWifiClientHistory(ctx, mac)
+		if err != nil {
+			continue
+		}
+
+		cursor, ok := r.clientHistories[mac]
+		if !ok {
+			cursor = new(historyCursor)
+			r.clientHistories[mac] = cursor
+		}
+		seqs, lost := cursor.advance(history.GetCurrent(), len(history.GetTxThroughputMbps()))
+		if lost > 0 {
+			log.WithFields(logrus.Fields{"MAC": mac, "Lost": lost}).
+				Warn("Client history samples overwritten between polls, consider a shorter interval")
+			r.promRouterClientHistoryLost.WithLabelValues(mac).Add(float64(lost))
+		}
+		r.ingestClientHistory(mac, seqs, history)
+	}
+}
+
+// Feeds client samples new since the last poll into per-second metrics
+func (r *routerDevice) ingestClientHistory(mac string, seqs []uint64, history *starlink.WifiGetClientHistoryResponse) {
+	tx := r.promRouterClientHistoryTxMbps.WithLabelValues(mac)
+	rx := r.promRouterClientHistoryRxMbps.WithLabelValues(mac)
+	rate := r.promRouterClientHistoryRxRateMbps.WithLabelValues(mac)
+	rssi := r.promRouterClientHistoryRssi.WithLabelValues(mac)
+	limited := history.GetThroughputLimited()
+	rssiBuf := history.GetRssi()
+
+	for _, seq := range seqs {
+		tx.Observe(historyValue(history.GetTxThroughputMbps(), seq))
+		rx.Observe(historyValue(history.GetRxThroughputMbps(), seq))
+		if rates := history.GetRxRateMbps(); len(rates) > 0 {
+			rate.Observe(historyValue(rates, seq))
+		}
+		// RSSI is packed as signed dBm, one byte per sample
+		if len(rssiBuf) > 0 {
+			rssi.Observe(float64(int8(rssiBuf[historyIndex(seq, len(rssiBuf))])))
+		}
+		if len(limited) > 0 {
+			reason := limited[historyIndex(seq, len(limited))]
+			r.promRouterClientLimitedSeconds.WithLabelValues(mac, reason.String()).Inc()
+		}
+	}
+}
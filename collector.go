@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collection modes
+const (
+	modeTicker = "ticker" // Update on a fixed interval, scrapes serve the last update
+	modeScrape = "scrape" // Update from Dishy during each scrape
+)
+
+// Holds every Dishy metric until promInit decides how to register them
+var dishyCollectors collectorSet
+
+// Records collectors rather than registering them, satisfies prometheus.Registerer
+type collectorSet []prometheus.Collector
+
+func (s *collectorSet) Register(c prometheus.Collector) error {
+	*s = append(*s, c)
+	return nil
+}
+
+func (s *collectorSet) MustRegister(cs ...prometheus.Collector) {
+	*s = append(*s, cs...)
+}
+
+func (s *collectorSet) Unregister(c prometheus.Collector) bool {
+	for i := range *s {
+		if (*s)[i] == c {
+			*s = append((*s)[:i], (*s)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Queries Dishy when scraped, results are cached so concurrent scrapers
+// don't each hit the dish
+type scrapeCollector struct {
+	mu         sync.Mutex
+	collectors []prometheus.Collector
+	update     func()
+	cacheTTL   time.Duration
+	lastUpdate time.Time
+}
+
+func newScrapeCollector(collectors []prometheus.Collector, update func(), cacheTTL time.Duration) *scrapeCollector {
+	return &scrapeCollector{
+		collectors: collectors,
+		update:     update,
+		cacheTTL:   cacheTTL,
+	}
+}
+
+// Implements prometheus.Collector
+func (c *scrapeCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range c.collectors {
+		collector.Describe(ch)
+	}
+}
+
+// Implements prometheus.Collector, updating first if the cache has expired
+func (c *scrapeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	if time.Since(c.lastUpdate) >= c.cacheTTL {
+		c.update()
+		c.lastUpdate = time.Now()
+	} else {
+		log.WithField("Age", time.Since(c.lastUpdate)).Trace("Serving cached metrics")
+	}
+	c.mu.Unlock()
+
+	for _, collector := range c.collectors {
+		collector.Collect(ch)
+	}
+}
+
+// Validates the collection mode flag
+func checkMode(mode string) error {
+	switch mode {
+	case modeTicker, modeScrape:
+		return nil
+	default:
+		return fmt.Errorf("unknown mode %q, expected %s or %s", mode, modeTicker, modeScrape)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Requests DishGetContext and updates cell and PoP assignment metrics
+func (d *dishDevice) updateContextMetrics(ctx context.Context) {
+	if d.contextUnsupported {
+		return
+	}
+
+	dishCtx, err := d.client.GetContext(ctx)
+	if isUnsupported(err) {
+		log.WithField("Error", err).Warn("Dish context not supported by firmware, disabling context metrics")
+		d.contextUnsupported = true
+		return
+	} else if err != nil {
+		return
+	}
+
+	// Assignment
+	setInfoLabels(d.promDishyContextInfo, &d.contextInfoLabels,
+		strconv.FormatUint(uint64(dishCtx.GetCellId()), 10),
+		strconv.FormatUint(uint64(dishCtx.GetPopRackId()), 10),
+		strconv.FormatUint(uint64(dishCtx.GetInitialSatelliteId()), 10),
+		strconv.FormatUint(uint64(dishCtx.GetInitialGatewayId()), 10),
+	)
+	d.promDishyContextCellID.Set(float64(dishCtx.GetCellId()))
+	d.promDishyContextPopRackID.Set(float64(dishCtx.GetPopRackId()))
+
+	var backupBeam float64
+	if dishCtx.GetOnBackupBeam() {
+		backupBeam = 1
+	}
+	d.promDishyContextBackupBeam.Set(backupBeam)
+	d.promDishyContextSecondsToSlotEnd.Set(float64(dishCtx.GetSecondsToSlotEnd()))
+
+	// 15s Means
+	d.promDishyContextPingDropRate.Set(float64(dishCtx.GetPopPingDropRate_15SMean()))
+	d.promDishyContextPingLatencyMs.Set(float64(dishCtx.GetPopPingLatencyMs_15SMean()))
+
+	// Time Since Outages
+	d.promDishyContextSinceOutage.WithLabelValues("1s").Set(float64(dishCtx.GetSecondsSinceLast_1SOutage()))
+	d.promDishyContextSinceOutage.WithLabelValues("2s").Set(float64(dishCtx.GetSecondsSinceLast_2SOutage()))
+	d.promDishyContextSinceOutage.WithLabelValues("5s").Set(float64(dishCtx.GetSecondsSinceLast_5SOutage()))
+	d.promDishyContextSinceOutage.WithLabelValues("15s").Set(float64(dishCtx.GetSecondsSinceLast_15SOutage()))
+	d.promDishyContextSinceOutage.WithLabelValues("60s").Set(float64(dishCtx.GetSecondsSinceLast_60SOutage()))
+
+	// Reassignments
+	if last := d.contextLast; last != nil {
+		if last.GetCellId() != dishCtx.GetCellId() {
+			log.WithFields(logrus.Fields{"From": last.GetCellId(), "To": dishCtx.GetCellId()}).Info("Dishy cell changed")
+			d.promDishyContextCellChanges.Inc()
+		}
+		if last.GetPopRackId() != dishCtx.GetPopRackId() {
+			log.WithFields(logrus.Fields{"From": last.GetPopRackId(), "To": dishCtx.GetPopRackId()}).Info("Dishy PoP rack changed")
+			d.promDishyContextPopRackChanges.Inc()
+		}
+	}
+	d.contextLast = dishCtx
+}
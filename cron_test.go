@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestCronNext(t *testing.T) {
+	tests := []struct {
+		expr, zone, from, want string
+	}{
+		{"0 */6 * * *", "UTC", "2024-03-10 01:30", "2024-03-10 06:00"},
+		{"0 */6 * * *", "Asia/Kolkata", "2024-03-10 01:30", "2024-03-10 06:00"},
+		{"0 */6 * * *", "Asia/Kathmandu", "2024-03-10 19:00", "2024-03-11 00:00"},
+		{"15 9 * * *", "Asia/Kolkata", "2024-03-10 09:15", "2024-03-11 09:15"},
+		{"*/20 * * * *", "UTC", "2024-03-10 01:41", "2024-03-10 02:00"},
+		{"5-10/2 * * * *", "UTC", "2024-03-10 01:06", "2024-03-10 01:07"},
+		{"0 8,20 * * *", "UTC", "2024-03-10 08:00", "2024-03-10 20:00"},
+		{"0 0 1,15 * *", "UTC", "2024-03-02 00:00", "2024-03-15 00:00"},
+		{"@monthly", "UTC", "2024-12-31 23:59", "2025-01-01 00:00"},
+		{"0 0 29 2 *", "UTC", "2024-03-01 00:00", "2028-02-29 00:00"},
+		// Day of month or day of week when both are restricted, 2024-03-11 is a Monday
+		{"0 12 20 * 1", "UTC", "2024-03-10 13:00", "2024-03-11 12:00"},
+		{"0 12 12 * 1", "UTC", "2024-03-11 13:00", "2024-03-12 12:00"},
+		// Day of week alone
+		{"0 12 * * 1", "UTC", "2024-03-11 13:00", "2024-03-18 12:00"},
+		// Spring forward skips 02:00 in New York, fall back repeats 01:00
+		{"30 2 * * *", "America/New_York", "2024-03-09 03:00", "2024-03-11 02:30"},
+		{"0 3 * * *", "America/New_York", "2024-03-10 00:30", "2024-03-10 03:00"},
+		{"0 2 * * *", "America/New_York", "2024-11-03 00:30", "2024-11-03 02:00"},
+		// Santiago skips midnight into DST
+		{"0 1 * * *", "America/Santiago", "2024-09-07 02:00", "2024-09-08 01:00"},
+		{"0 12 8 9 *", "America/Santiago", "2024-09-07 02:00", "2024-09-08 12:00"},
+	}
+	for _, tt := range tests {
+		loc, err := time.LoadLocation(tt.zone)
+		if err != nil {
+			t.Fatal(err)
+		}
+		from, err := time.ParseInLocation("2006-01-02 15:04", tt.from, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := time.ParseInLocation("2006-01-02 15:04", tt.want, loc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		schedule, err := parseCron(tt.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.expr, err)
+		}
+		if got := schedule.next(from); !got.Equal(want) {
+			t.Errorf("%q in %s after %s: got %s, want %s", tt.expr, tt.zone, tt.from, got, want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 7",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("expected %q to be rejected", expr)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+)
+
+// Geofence config file, disabled if empty
+var geofenceFile string
+
+func init() {
+	flag.StringVar(&geofenceFile, "geofences", geofenceFile, "JSON file of geofences to evaluate the Dishy location against (disabled if empty, requires local location access)")
+}
+
+// A circle or polygon the dish is expected to stay within, e.g.
+//
+//	[{"name": "home", "lat": 45.52, "lon": -122.68, "radius_m": 200},
+//	 {"name": "yard", "polygon": [[45.50, -122.70], [45.50, -122.60], [45.55, -122.65]]}]
+type geofence struct {
+	Name    string       `json:"name"`
+	Lat     float64      `json:"lat"`
+	Lon     float64      `json:"lon"`
+	RadiusM float64      `json:"radius_m"`
+	Polygon [][2]float64 `json:"polygon"` // Vertices as [lat, lon]
+}
+
+// Configured geofences and whether the dish was inside each at the last poll
+var (
+	geofences      []geofence
+	geofenceInside = make(map[string]bool)
+)
+
+// Loads and validates geofences from the config file
+func loadGeofences() error {
+	if geofenceFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(geofenceFile)
+	if err != nil {
+		return fmt.Errorf("unable to read geofences: %w", err)
+	}
+	var fences []geofence
+	if err := json.Unmarshal(data, &fences); err != nil {
+		return fmt.Errorf("unable to parse geofences %s: %w", geofenceFile, err)
+	}
+
+	names := make(map[string]bool, len(fences))
+	for i, f := range fences {
+		if f.Name == "" {
+			return fmt.Errorf("geofence %d has no name", i)
+		}
+		if names[f.Name] {
+			return fmt.Errorf("duplicate geofence %q", f.Name)
+		}
+		names[f.Name] = true
+		if len(f.Polygon) == 0 && f.RadiusM <= 0 {
+			return fmt.Errorf("geofence %q needs a radius_m or polygon", f.Name)
+		}
+		if len(f.Polygon) > 0 && len(f.Polygon) < 3 {
+			return fmt.Errorf("geofence %q polygon needs at least 3 vertices", f.Name)
+		}
+	}
+	geofences = fences
+	log.WithField("Geofences", len(geofences)).Info("Loaded geofences")
+	return nil
+}
+
+// Reports whether a position is inside the fence
+func (f geofence) contains(lat, lon float64) bool {
+	if len(f.Polygon) == 0 {
+		return haversine(f.Lat, f.Lon, lat, lon) <= f.RadiusM
+	}
+
+	// Ray casting, fences are small enough to treat degrees as planar
+	inside := false
+	for i, j := 0, len(f.Polygon)-1; i < len(f.Polygon); j, i = i, i+1 {
+		a, b := f.Polygon[i], f.Polygon[j]
+		if (a[0] > lat) != (b[0] > lat) &&
+			lon < (b[1]-a[1])*(lat-a[0])/(b[0]-a[0])+a[1] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Evaluates geofences against a position, counting and logging transitions
+func updateGeofenceMetrics(lla *starlink.LLAPosition) {
+	if len(geofences) == 0 {
+		return
+	}
+	for _, f := range geofences {
+		inside := f.contains(lla.GetLat(), lla.GetLon())
+		last, known := geofenceInside[f.Name]
+		geofenceInside[f.Name] = inside
+
+		var insideVal float64
+		if inside {
+			insideVal = 1
+		}
+		promDishyGeofenceInside.WithLabelValues(f.Name).Set(insideVal)
+
+		if !known || last == inside {
+			continue
+		}
+		if inside {
+			promDishyGeofenceTransitions.WithLabelValues(f.Name, "enter").Inc()
+			log.WithField("Geofence", f.Name).Info("Dishy entered geofence")
+		} else {
+			promDishyGeofenceTransitions.WithLabelValues(f.Name, "exit").Inc()
+			log.WithField("Geofence", f.Name).Warn("Dishy left geofence")
+		}
+	}
+	promDishyGeofenceEvaluated.Set(float64(time.Now().Unix()))
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// GRPC connection settings
+var (
+	dialTimeout      string = "5s"  // Timeout for each connection attempt
+	keepaliveTime    string = "0"   // Ping Dishy after this long without activity, disabled by default
+	keepaliveTimeout string = "10s" // Wait this long for a keepalive ack
+	backoffBase      string = "1s"  // Delay before the first reconnect
+	backoffMax       string = "2m"  // Upper bound on the reconnect delay
+	useTLS           bool           // Use TLS rather than plaintext
+	tlsCA            string         // Optional CA bundle for TLS
+	tlsSkipVerify    bool           // Skip TLS certificate verification
+)
+
+func init() {
+	flag.StringVar(&dialTimeout, "dialTimeout", dialTimeout, "Timeout for each attempt to connect to the GRPC endpoint (go time.Duration)")
+	flag.StringVar(&keepaliveTime, "keepaliveTime", keepaliveTime, "Interval between GRPC keepalive pings, 0 to disable, servers commonly reject pings more often than 5m (go time.Duration)")
+	flag.StringVar(&keepaliveTimeout, "keepaliveTimeout", keepaliveTimeout, "Time to wait for a GRPC keepalive ack (go time.Duration)")
+	flag.StringVar(&backoffBase, "backoffBase", backoffBase, "Initial delay between GRPC reconnect attempts (go time.Duration)")
+	flag.StringVar(&backoffMax, "backoffMax", backoffMax, "Maximum delay between GRPC reconnect attempts (go time.Duration)")
+	flag.BoolVar(&useTLS, "tls", useTLS, "Connect to the GRPC endpoint using TLS instead of plaintext")
+	flag.StringVar(&tlsCA, "tlsCA", tlsCA, "PEM CA bundle used to verify the GRPC endpoint (implies -tls)")
+	flag.BoolVar(&tlsSkipVerify, "tlsSkipVerify", tlsSkipVerify, "Skip verification of the GRPC endpoint certificate (implies -tls)")
+}
+
+// Connection options resolved from flags
+type dialConfig struct {
+	target           string
+	timeout          time.Duration
+	keepaliveTime    time.Duration
+	keepaliveTimeout time.Duration
+	backoff          backoff.Config
+	creds            credentials.TransportCredentials
+}
+
+// Validates GRPC flags and builds the dial configuration for target
+func newDialConfig(target string) (*dialConfig, error) {
+	if _, port, err := net.SplitHostPort(target); err != nil {
+		return nil, fmt.Errorf("invalid endpoint %q: %w", target, err)
+	} else if port == "" {
+		return nil, fmt.Errorf("invalid endpoint %q: missing port", target)
+	}
+
+	conf := &dialConfig{target: target}
+	var err error
+	if conf.timeout, err = parseDurationFlag("dialTimeout", dialTimeout); err != nil {
+		return nil, err
+	}
+	if conf.keepaliveTime, err = parseDurationFlag("keepaliveTime", keepaliveTime); err != nil {
+		return nil, err
+	}
+	if conf.keepaliveTimeout, err = parseDurationFlag("keepaliveTimeout", keepaliveTimeout); err != nil {
+		return nil, err
+	}
+
+	// Exponential reconnect backoff
+	conf.backoff = backoff.DefaultConfig
+	if conf.backoff.BaseDelay, err = parseDurationFlag("backoffBase", backoffBase); err != nil {
+		return nil, err
+	}
+	if conf.backoff.MaxDelay, err = parseDurationFlag("backoffMax", backoffMax); err != nil {
+		return nil, err
+	}
+	if conf.backoff.MaxDelay < conf.backoff.BaseDelay {
+		return nil, fmt.Errorf("-backoffMax %s is less than -backoffBase %s", backoffMax, backoffBase)
+	}
+
+	// Plaintext unless any TLS option was given
+	conf.creds = insecure.NewCredentials()
+	if useTLS || tlsCA != "" || tlsSkipVerify {
+		tlsConf := &tls.Config{InsecureSkipVerify: tlsSkipVerify}
+		if tlsCA != "" {
+			pem, err := os.ReadFile(tlsCA)
+			if err != nil {
+				return nil, fmt.Errorf("unable to read tlsCA: %w", err)
+			}
+			tlsConf.RootCAs = x509.NewCertPool()
+			if !tlsConf.RootCAs.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in tlsCA %s", tlsCA)
+			}
+		}
+		conf.creds = credentials.NewTLS(tlsConf)
+	}
+
+	return conf, nil
+}
+
+// Returns GRPC dial options for this configuration
+func (c *dialConfig) options() []grpc.DialOption {
+	opts := []grpc.DialOption{
+		grpc.WithTransportCredentials(c.creds),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff:           c.backoff,
+			MinConnectTimeout: c.timeout,
+		}),
+	}
+	if c.keepaliveTime > 0 {
+		opts = append(opts, grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                c.keepaliveTime,
+			Timeout:             c.keepaliveTimeout,
+			PermitWithoutStream: true,
+		}))
+	}
+	return opts
+}
+
+// Dials the endpoint without blocking, GRPC reconnects in the background
+func (c *dialConfig) dial() (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial(c.target, c.options()...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect to %s: %w", c.target, err)
+	}
+	return conn, nil
+}
+
+// Tracks connection state, signalling ready (if not nil) each time the connection comes up
+func watchConnState(conn *grpc.ClientConn, name string, states *prometheus.GaugeVec, ready chan<- struct{}) {
+	state := conn.GetState()
+	for {
+		log.WithFields(logrus.Fields{"Endpoint": conn.Target(), "State": state}).
+			Debug("GRPC connection state changed")
+
+		for _, s := range connStates {
+			var current float64
+			if s == state {
+				current = 1
+			}
+			states.WithLabelValues(connStateLabel(s)).Set(current)
+		}
+
+		switch state {
+		case connectivity.Ready:
+			log.WithField("Endpoint", conn.Target()).Infof("GRPC Connected to %s", name)
+			if ready != nil {
+				select {
+				case ready <- struct{}{}:
+				default:
+				}
+			}
+		case connectivity.TransientFailure:
+			log.WithField("Endpoint", conn.Target()).Warnf("GRPC connection to %s failed, retrying", name)
+		case connectivity.Idle:
+			// Stay connected rather than waiting for the next request
+			conn.Connect()
+		}
+
+		if !conn.WaitForStateChange(context.Background(), state) {
+			return
+		}
+		state = conn.GetState()
+	}
+}
+
+// GRPC connection states exported as metrics
+var connStates = []connectivity.State{
+	connectivity.Idle,
+	connectivity.Connecting,
+	connectivity.Ready,
+	connectivity.TransientFailure,
+	connectivity.Shutdown,
+}
+
+// Returns a metric label for a connection state (e.g. transient_failure)
+func connStateLabel(s connectivity.State) string {
+	return strings.ToLower(s.String())
+}
+
+// Parses a duration flag, naming the flag on failure
+func parseDurationFlag(name string, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid -%s %q: %w", name, value, err)
+	}
+	return d, nil
+}
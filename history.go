@@ -0,0 +1,88 @@
+package main
+
+// Tracks position in a device history ring buffer between polls. Devices
+// report Current, the total number of samples ever written, and buffers
+// where sample n lives at index n % len(buffer).
+type historyCursor struct {
+	current uint64 // Current as of the last poll
+	started bool   // Whether any poll has been seen
+}
+
+// Advances the cursor to current, returning the sequence numbers of samples
+// written since the last poll (oldest first) and the number of samples that
+// were overwritten before they could be read. The first poll returns the
+// entire buffer.
+func (h *historyCursor) advance(current uint64, size int) (seqs []uint64, lost uint64) {
+	if size == 0 {
+		return nil, 0
+	}
+
+	from := h.current
+	if !h.started || current < h.current {
+		// First poll, or the device restarted and reset its counter
+		from = 0
+	}
+	h.current = current
+	h.started = true
+
+	// Anything older than one buffer length has been overwritten
+	if oldest := current - min64(current, uint64(size)); from < oldest {
+		if from > 0 {
+			lost = oldest - from
+		}
+		from = oldest
+	}
+
+	for seq := from; seq < current; seq++ {
+		seqs = append(seqs, seq)
+	}
+	return seqs, lost
+}
+
+// Returns the ring buffer index for a sequence number
+func historyIndex(seq uint64, size int) int {
+	return int(seq % uint64(size))
+}
+
+// Feeds samples new since the last poll into per-second histograms
+func (d *dishDevice) ingestHistory(seqs []uint64, drops, latencies, downlink, uplink []float32) {
+	for _, seq := range seqs {
+		drop := historyValue(drops, seq)
+		d.promDishyHistoryPingDropRate.Observe(drop)
+		d.promDishyHistoryPingDropSeconds.Add(drop)
+		// Latency is meaningless for a second with every ping dropped
+		if drop < 1 {
+			d.promDishyHistoryPingLatencyMs.Observe(historyValue(latencies, seq))
+		}
+		d.promDishyHistoryDLTputBps.Observe(historyValue(downlink, seq))
+		d.promDishyHistoryULTputBps.Observe(historyValue(uplink, seq))
+	}
+	d.promDishyHistorySamples.Add(float64(len(seqs)))
+}
+
+// Feeds router samples new since the last poll into per-second metrics
+func (r *routerDevice) ingestHistory(seqs []uint64, drops, latencies []float32) {
+	for _, seq := range seqs {
+		drop := historyValue(drops, seq)
+		r.promRouterHistoryPingDropSeconds.Add(drop)
+		if drop < 1 {
+			r.promRouterHistoryPingLatencyMs.Observe(historyValue(latencies, seq))
+		}
+	}
+	r.promRouterHistorySamples.Add(float64(len(seqs)))
+}
+
+// Returns the value of a ring buffer sample, zero if the buffer is short
+func historyValue(buf []float32, seq uint64) float64 {
+	if len(buf) == 0 {
+		return 0
+	}
+	return float64(buf[historyIndex(seq, len(buf))])
+}
+
+func min64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
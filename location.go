@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+)
+
+// Location precision modes
+const (
+	locationOff     = "off"     // Don't request location
+	locationFull    = "full"    // Export coordinates as reported
+	locationRound   = "round"   // Round coordinates to locationDecimals places
+	locationGeohash = "geohash" // Export only a geohash prefix and its center
+)
+
+// Location settings, off by default so fixed sites don't leak coordinates
+var (
+	locationMode       string = locationOff
+	locationDecimals   int    = 2 // ~1.1km of latitude
+	locationGeohashLen int    = 5 // ~4.9km cells
+)
+
+func init() {
+	flag.StringVar(&locationMode, "location", locationMode, "Export Dishy GPS location: off, full, round or geohash (requires local location access enabled in the Starlink app)")
+	flag.IntVar(&locationDecimals, "locationDecimals", locationDecimals, "Decimal places kept with -location round")
+	flag.IntVar(&locationGeohashLen, "locationGeohashLength", locationGeohashLen, "Geohash characters kept with -location geohash")
+}
+
+// Validates location flags
+func checkLocationMode() error {
+	switch locationMode {
+	case locationOff, locationFull:
+	case locationRound:
+		if locationDecimals < 0 {
+			return fmt.Errorf("locationDecimals must not be negative")
+		}
+	case locationGeohash:
+		if locationGeohashLen < 1 || locationGeohashLen > 12 {
+			return fmt.Errorf("locationGeohashLength must be between 1 and 12")
+		}
+	default:
+		return fmt.Errorf("unknown location mode %q, expected %s, %s, %s or %s",
+			locationMode, locationOff, locationFull, locationRound, locationGeohash)
+	}
+	return nil
+}
+
+// Requests GetLocation and updates location metrics at the configured precision
+func (d *dishDevice) updateLocationMetrics(ctx context.Context) {
+	// The track and geofences use full precision locally regardless of export precision
+	local := d.primary && (trackFile != "" || len(geofences) > 0)
+	if (locationMode == locationOff && !local) || d.locationUnsupported {
+		return
+	}
+
+	resp, err := d.client.GetLocation(ctx)
+	if isUnsupported(err) {
+		log.WithField("Error", err).Warn("Location not available, enable local location access in the Starlink app, disabling location metrics")
+		d.locationUnsupported = true
+		return
+	} else if err != nil {
+		return
+	}
+	lla := resp.GetLla()
+	if lla == nil {
+		return
+	}
+	if d.primary {
+		recordTrackPosition(lla)
+		updateGeofenceMetrics(lla)
+	}
+	if locationMode == locationOff {
+		return
+	}
+
+	lat, lon, alt := exportPosition(lla.GetLat(), lla.GetLon(), lla.GetAlt())
+	if locationMode == locationGeohash {
+		setInfoLabels(d.promDishyLocationGeohash, &d.geohashLabels, geohashEncode(lat, lon, locationGeohashLen))
+	}
+
+	d.promDishyLocationLatitude.Set(lat)
+	d.promDishyLocationLongitude.Set(lon)
+	// Altitude narrows a geohash cell down too far
+	if locationMode != locationGeohash {
+		d.promDishyLocationAltitude.Set(alt)
+	}
+}
+
+// Reduces a position to the configured export precision, geohash drops altitude
+func exportPosition(lat, lon, alt float64) (float64, float64, float64) {
+	switch locationMode {
+	case locationRound:
+		return roundTo(lat, locationDecimals), roundTo(lon, locationDecimals), math.Round(alt)
+	case locationGeohash:
+		lat, lon = geohashCenter(geohashEncode(lat, lon, locationGeohashLen))
+		return lat, lon, 0
+	}
+	return lat, lon, alt
+}
+
+// Rounds v to places decimal places
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
+}
+
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Returns the geohash of a position with length characters
+func geohashEncode(lat, lon float64, length int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	hash := make([]byte, 0, length)
+	var bits, ch int
+	even := true
+	for len(hash) < length {
+		// Bits alternate between longitude and latitude, longitude first
+		r, v := &latRange, lat
+		if even {
+			r, v = &lonRange, lon
+		}
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if v >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+
+		if bits++; bits == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bits, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
+
+// Returns the center of a geohash cell
+func geohashCenter(hash string) (lat, lon float64) {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+	even := true
+	for i := 0; i < len(hash); i++ {
+		ch := int64(-1)
+		for j := 0; j < len(geohashAlphabet); j++ {
+			if geohashAlphabet[j] == hash[i] {
+				ch = int64(j)
+				break
+			}
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if even {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if ch>>uint(bit)&1 == 1 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			even = !even
+		}
+	}
+	return (latRange[0] + latRange[1]) / 2, (lonRange[0] + lonRange[1]) / 2
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/signal"
 	"reflect"
+	"strconv"
 	"sync"
 	"syscall"
 	"time"
@@ -33,32 +34,69 @@ var alerts = []string{
 
 // Setup
 var (
-	host     string = "192.168.100.1:9000" // Default for Dishy
-	promAddr string = "0.0.0.0:9982"       // Listen address for Prometheus
-	interval string = "30s"                // Update seconds
-	logLevel string = "info"               // Logging Level
+	host        string = "192.168.100.1:9200" // Default for Dishy
+	promAddr    string = "0.0.0.0:9982"       // Listen address for Prometheus
+	interval    string = "30s"                // Update seconds
+	logLevel    string = "info"               // Logging Level
+	mode        string = modeTicker           // Collection mode
+	scrapeCache string = "5s"                 // Reuse results for scrapes within this window
 )
 
-//Shared Variables
+// Shared Variables
 var (
-	client       starlink.DeviceClient                         // GRPC Connection to Dishy
-	dishy        *starlink.Request     = new(starlink.Request) // Dishy GRPC Request Provider
-	log          *logrus.Logger        = logrus.New()          // Logrus logger
-	dishyLabels  prometheus.Labels
-	wg           sync.WaitGroup
-	latestOutage int64
+	dishy     *dishDevice                   // Dishy and its update state
+	routerDev *routerDevice                 // Router and its update state, nil if disabled
+	client    *deviceClient                 // GRPC Connection to Dishy
+	router    *deviceClient                 // GRPC Connection to the router, nil if disabled
+	log       *logrus.Logger = logrus.New() // Logrus logger
+	wg        sync.WaitGroup
 )
 
+// A dish, the metrics its updaters write and the state they carry between
+// polls. Probes keep one per target, only the primary dish feeds
+// remote write, the track, geofences and the reboot log
+type dishDevice struct {
+	*dishMetricSet
+	client  *deviceClient
+	primary bool
+
+	labels       prometheus.Labels
+	labelsLock   sync.Mutex // Guards labels, refreshed outside of updates
+	history      historyCursor
+	latestOutage int64
+	wedges       int // Wedges in the last status, series are reset when this changes
+
+	// Set once the firmware rejects a request, stops further requests
+	transceiverUnsupported bool
+	telemetryUnsupported   bool
+	contextUnsupported     bool
+	locationUnsupported    bool
+
+	telemetryLast     *starlink.TransceiverGetTelemetryResponse
+	telemetryLock     sync.Mutex // Guards telemetryLast, read by the track recorder
+	contextLast       *starlink.DishGetContextResponse
+	contextInfoLabels []string
+	geohashLabels     []string
+	bootcountLast     int32
+	bootcountKnown    bool
+	bootReasonsLast   map[int32]int32
+}
+
+func newDishDevice(conn grpc.ClientConnInterface, m *dishMetricSet) *dishDevice {
+	return &dishDevice{
+		dishMetricSet: m,
+		client:        newDishClient(conn, m),
+	}
+}
+
 func init() {
 	// Handle flags
 	flag.StringVar(&host, "host", host, "IP and port of Dishy GRPC endpoint")
 	flag.StringVar(&interval, "interval", interval, "Update interval (go time.Duration e.g. 1m30s)")
 	flag.StringVar(&promAddr, "promAddr", promAddr, "Listen address and port for Prometheus /metrics")
 	flag.StringVar(&logLevel, "logLevel", logLevel, "Logging level (error, warn, info, debug, trace)")
-	flag.Parse()
-
-	// Set logging
-	setLogLevel()
+	flag.StringVar(&mode, "mode", mode, "Collection mode, ticker updates every -interval, scrape queries Dishy on each scrape")
+	flag.StringVar(&scrapeCache, "scrapeCache", scrapeCache, "In scrape mode, serve cached results to scrapes within this window (go time.Duration)")
 }
 
 func UpdateMetrics() {
@@ -68,105 +106,220 @@ func UpdateMetrics() {
 	t1 := time.Now()
 	log.Debug("Updating Metrics")
 
-	log.Trace("Updating Info Metrics")
-	updateInfoMetrics()
-	log.Trace("Updating Status Metrics")
-	updateStatusMetrics()
-	log.Trace("Updating History Metrics")
-	updateHistoryMetrics()
+	updaters := dishy.updaters()
+	if routerDev != nil {
+		for name, update := range routerDev.updaters() {
+			updaters[name] = update
+		}
+	}
+	runUpdaters(context.Background(), updaters)
 
 	promDishyUpdates.Inc()
 	promDishyUpdateTime.Observe(float64(time.Now().Sub(t1).Milliseconds()))
 }
 
+// Runs updaters concurrently, they are independent so an update takes as
+// long as the slowest RPC
+func runUpdaters(ctx context.Context, updaters map[string]func(context.Context)) {
+	var updates sync.WaitGroup
+	for name, update := range updaters {
+		updates.Add(1)
+		go func(name string, update func(context.Context)) {
+			defer updates.Done()
+			log.Tracef("Updating %s Metrics", name)
+			update(ctx)
+		}(name, update)
+	}
+	updates.Wait()
+}
+
+// Returns the dish's updaters by name
+func (d *dishDevice) updaters() map[string]func(context.Context) {
+	return map[string]func(context.Context){
+		"Info":        d.updateInfoMetrics,
+		"Status":      d.updateStatusMetrics,
+		"History":     d.updateHistoryMetrics,
+		"Transceiver": d.updateTransceiverMetrics,
+		"Telemetry":   d.updateTelemetryMetrics,
+		"Context":     d.updateContextMetrics,
+		"Location":    d.updateLocationMetrics,
+	}
+}
+
 // Requests GetDeviceInfo and updated relevant metrics
-func updateInfoMetrics() {
+func (d *dishDevice) updateInfoMetrics(ctx context.Context) {
 	// Fetch DeviceInfo
-	dishy.Request = &starlink.Request_GetDeviceInfo{}
-	info, err := getRequest()
+	info, err := d.client.GetDeviceInfo(ctx)
 	if err != nil {
 		return
 	}
 
+	d.labelsLock.Lock()
+	defer d.labelsLock.Unlock()
+
+	// Info, refreshed every poll so firmware upgrades are picked up
+	d.setLabels(info)
+
 	// Boot Count
-	promDishyBootcount.With(dishyLabels).
-		Set(float64(info.GetGetDeviceInfo().DeviceInfo.Bootcount))
+	d.promDishyBootcount.With(prometheus.Labels{"id": d.labels["id"]}).
+		Set(float64(info.GetBootcount()))
+	d.updateBootMetrics(info)
 }
 
-func updateStatusMetrics() {
+// Requests GetDeviceInfo and rebuilds Dishy info labels
+func (d *dishDevice) refreshLabels() {
+	info, err := d.client.GetDeviceInfo(context.Background())
+	if err != nil {
+		return
+	}
+	d.labelsLock.Lock()
+	d.setLabels(info)
+	d.labelsLock.Unlock()
+}
+
+// Sets Dishy info labels, removing series for any previous label set,
+// callers must hold labelsLock
+func (d *dishDevice) setLabels(info *starlink.DeviceInfo) {
+	labels := prometheus.Labels{
+		"id":                        info.GetId(),
+		"country_code":              info.GetCountryCode(),
+		"hardware_version":          info.GetHardwareVersion(),
+		"software_version":          info.GetSoftwareVersion(),
+		"manufactured_version":      info.GetManufacturedVersion(),
+		"is_dev":                    strconv.FormatBool(info.GetIsDev()),
+		"is_hitl":                   strconv.FormatBool(info.GetIsHitl()),
+		"software_partitions_equal": strconv.FormatBool(info.GetSoftwarePartitionsEqual()),
+		"utc_offset_s":              strconv.Itoa(int(info.GetUtcOffsetS())),
+	}
+	if d.labels != nil && !reflect.DeepEqual(d.labels, labels) {
+		log.WithFields(logrus.Fields{"Old": d.labels, "New": labels}).Info("Dishy info changed")
+		d.promDishyInfo.Delete(d.labels)
+		if d.labels["id"] != labels["id"] {
+			d.promDishyBootcount.Delete(prometheus.Labels{"id": d.labels["id"]})
+		}
+	}
+	d.labels = labels
+	d.promDishyInfo.With(labels).Set(1)
+}
+
+// Sets an info series to 1, deleting the previous series only once its
+// labels change so it never disappears between polls
+func setInfoLabels(vec *prometheus.GaugeVec, last *[]string, values ...string) {
+	if *last != nil && !reflect.DeepEqual(*last, values) {
+		vec.DeleteLabelValues(*last...)
+	}
+	*last = values
+	vec.WithLabelValues(values...).Set(1)
+}
+
+func (d *dishDevice) updateStatusMetrics(ctx context.Context) {
 	// Fetch Dishy Status
-	dishy.Request = &starlink.Request_GetStatus{}
-	status, err := getRequest()
+	dishStatus, err := d.client.GetStatus(ctx)
 	if err != nil {
 		return
 	}
-	dishStatus := status.GetDishGetStatus()
 
 	// GPS Statistics
 	var GPSValid float64
 	if dishStatus.GetGpsStats().GpsValid {
 		GPSValid = 1
 	}
-	promDishyGPSValid.Set(GPSValid)
-	promDishyGPSSats.Set(float64(dishStatus.GetGpsStats().GetGpsSats()))
+	d.promDishyGPSValid.Set(GPSValid)
+	d.promDishyGPSSats.Set(float64(dishStatus.GetGpsStats().GetGpsSats()))
 
 	// Currently In Outage
 	var inOutage float64
 	if dishStatus.Outage != nil {
 		inOutage = 1
 	}
-	promDishyOutage.Set(inOutage)
+	d.promDishyOutage.Set(inOutage)
 
 	// Current Obstructed State
 	var obstructed float64
 	if dishStatus.ObstructionStats.CurrentlyObstructed {
 		obstructed = 1
 	}
-	promDishyObstructed.Set(obstructed)
+	d.promDishyObstructed.Set(obstructed)
+
+	// Prolonged Obstruction Averages Valid
+	var avgValid float64
+	if dishStatus.GetObstructionStats().GetAvgProlongedObstructionValid() {
+		avgValid = 1
+	}
+	d.promDishyAvgObstructedValid.Set(avgValid)
+
+	// Obstruction by Wedge
+	d.updateWedgeMetrics(dishStatus.GetObstructionStats())
 
 	// Device Alert Booleans
 	for _, name := range alerts {
-		promDishyAlertStatus.WithLabelValues(name).
+		d.promDishyAlertStatus.WithLabelValues(name).
 			Set(isAlerting(dishStatus.Alerts, name))
 	}
 
 	// Status Metrics
-	promDishyUptimeS.Set(float64(dishStatus.GetDeviceState().GetUptimeS()))
-	promDishyAlerts.Set(countAlerts(dishStatus.Alerts))
-	promDishyFractionObstructed.Set(float64(dishStatus.ObstructionStats.GetFractionObstructed()))
-	promDishyAvgObstructedDurationS.Set(float64(dishStatus.GetObstructionStats().GetAvgProlongedObstructionDurationS()))
-	promDishyPopPingDropRate.Set(float64(dishStatus.GetPopPingDropRate()))
-	promDishyPopPingLatencyMs.Set(float64(dishStatus.GetPopPingLatencyMs()))
-	promDishyDLTputBps.Set(float64(dishStatus.GetDownlinkThroughputBps()))
-	promDishyULTputBps.Set(float64(dishStatus.GetUplinkThroughputBps()))
-	promDishyAzimuthDeg.Set(float64(dishStatus.GetBoresightAzimuthDeg()))
-	promDishyElevationDeg.Set(float64(dishStatus.GetBoresightElevationDeg()))
-	promDishyEthSpeedMbps.Set(float64(dishStatus.GetEthSpeedMbps()))
+	d.promDishyUptimeS.Set(float64(dishStatus.GetDeviceState().GetUptimeS()))
+	d.promDishyAlerts.Set(countAlerts(dishStatus.Alerts))
+	d.promDishyFractionObstructed.Set(float64(dishStatus.ObstructionStats.GetFractionObstructed()))
+	d.promDishyAvgObstructedDurationS.Set(float64(dishStatus.GetObstructionStats().GetAvgProlongedObstructionDurationS()))
+	d.promDishyAvgObstructedIntervalS.Set(float64(dishStatus.GetObstructionStats().GetAvgProlongedObstructionIntervalS()))
+	d.promDishyObstructionValidS.Set(float64(dishStatus.GetObstructionStats().GetValidS()))
+	d.promDishyPopPingDropRate.Set(float64(dishStatus.GetPopPingDropRate()))
+	d.promDishyPopPingLatencyMs.Set(float64(dishStatus.GetPopPingLatencyMs()))
+	d.promDishyDLTputBps.Set(float64(dishStatus.GetDownlinkThroughputBps()))
+	d.promDishyULTputBps.Set(float64(dishStatus.GetUplinkThroughputBps()))
+	d.promDishyAzimuthDeg.Set(float64(dishStatus.GetBoresightAzimuthDeg()))
+	d.promDishyElevationDeg.Set(float64(dishStatus.GetBoresightElevationDeg()))
+	d.promDishyEthSpeedMbps.Set(float64(dishStatus.GetEthSpeedMbps()))
 }
 
 // Update History Metricis
-func updateHistoryMetrics() {
+func (d *dishDevice) updateHistoryMetrics(ctx context.Context) {
 	// Fetch History Metrics
-	dishy.Request = &starlink.Request_GetHistory{}
-	history, err := getRequest()
+	history, err := d.client.GetHistory(ctx)
 	if err != nil {
 		return
 	}
+	polled := time.Now()
+
+	// Per-second samples since the last poll
+	seqs, lost := d.history.advance(history.GetCurrent(), len(history.GetPopPingDropRate()))
+	if lost > 0 {
+		log.WithField("Lost", lost).Warn("History samples overwritten between polls, consider a shorter interval")
+		d.promDishyHistorySamplesLost.Add(float64(lost))
+	}
+	d.ingestHistory(seqs,
+		history.GetPopPingDropRate(),
+		history.GetPopPingLatencyMs(),
+		history.GetDownlinkThroughputBps(),
+		history.GetUplinkThroughputBps())
+	if d.primary && remoteWriter != nil {
+		remoteWriter.enqueueHistory(seqs, history.GetCurrent(), polled,
+			history.GetPopPingDropRate(),
+			history.GetPopPingLatencyMs(),
+			history.GetDownlinkThroughputBps(),
+			history.GetUplinkThroughputBps())
+	}
 
 	// Outage History
-	outages := history.GetDishGetHistory().GetOutages()
+	outages := history.GetOutages()
+	if d.primary {
+		recordTrackOutages(outages)
+	}
 
 	// Outage Histogram
 	// Steps backwards, observing any newly seen outages
 	for i := len(outages) - 1; i >= 0; i-- {
-		if outages[i].GetStartTimestampNs() > latestOutage {
-			promDishyOutageHistogram.Observe(float64(outages[i].GetDurationNs() / 1e9))
+		if outages[i].GetStartTimestampNs() > d.latestOutage {
+			d.promDishyOutageHistogram.Observe(float64(outages[i].GetDurationNs() / 1e9))
 		} else {
 			break
 		}
 	}
 	// Advance our latest timestamp
-	latestOutage = outages[len(outages)-1].GetStartTimestampNs()
+	if len(outages) > 0 {
+		d.latestOutage = outages[len(outages)-1].GetStartTimestampNs()
+	}
 
 	// Calculate Count/Sum/Avg Outage Durations by Cause
 	durationSums := make(map[string]float64)
@@ -176,20 +329,21 @@ func updateHistoryMetrics() {
 		durationCounts[outage.GetCause().String()]++
 	}
 	for cause := range durationSums {
-		promDishyAvgOutageDuration.WithLabelValues(cause).
+		d.promDishyAvgOutageDuration.WithLabelValues(cause).
 			Set(durationSums[cause] / durationCounts[cause])
-		promDishySumOutageDuration.WithLabelValues(cause).
+		d.promDishySumOutageDuration.WithLabelValues(cause).
 			Set(durationSums[cause])
-		promDishyOutages.WithLabelValues(cause).
+		d.promDishyOutages.WithLabelValues(cause).
 			Set(durationCounts[cause])
 	}
 }
 
-// Returns the status of an alert as float64
-func isAlerting(a *starlink.DishAlerts, alert string) float64 {
+// Returns the status of an alert as float64, a is a pointer to any
+// struct of alert booleans such as DishAlerts
+func isAlerting(a interface{}, alert string) float64 {
 	var firing float64
-	r := reflect.ValueOf(a)
-	if reflect.Indirect(r).FieldByName(alert).Bool() {
+	r := reflect.Indirect(reflect.ValueOf(a))
+	if r.IsValid() && r.FieldByName(alert).Bool() {
 		firing = 1
 	}
 	return firing
@@ -208,41 +362,106 @@ func countAlerts(a *starlink.DishAlerts) float64 {
 }
 
 func main() {
-	// Connect
-	ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
-	defer cancel()
-	conn, err := grpc.DialContext(ctx, "192.168.100.1:9200", grpc.WithInsecure(), grpc.WithBlock())
+	// Flags are registered by init() in each file
+	flag.Parse()
+
+	// Set logging
+	setLogLevel()
+
+	// Commands that run instead of the exporter
+	switch flag.Arg(0) {
+	case "":
+	case "track":
+		if err := trackCommand(flag.Args()[1:]); err != nil {
+			log.WithField("Error", err).Fatal("Failed to export track")
+		}
+		return
+	default:
+		log.WithField("Command", flag.Arg(0)).Fatal("Unknown command")
+	}
+
+	// Validate connection settings
+	dialConf, err := newDialConfig(host)
 	if err != nil {
-		log.Fatalf("Failed to connect to dishy: %+v", err)
+		log.WithFields(logrus.Fields{"Endpoint": host, "Error": err}).
+			Fatal("Invalid GRPC configuration")
+	}
+
+	// Validate collection mode
+	if err := checkMode(mode); err != nil {
+		log.WithField("Error", err).Fatal("Invalid collection mode")
+	}
+	if err := checkLocationMode(); err != nil {
+		log.WithField("Error", err).Fatal("Invalid location configuration")
+	}
+	if err := loadTrack(); err != nil {
+		log.WithField("Error", err).Fatal("Failed to load position track")
+	}
+	if err := loadGeofences(); err != nil {
+		log.WithField("Error", err).Fatal("Invalid geofence configuration")
+	}
+	cacheTTL, err := time.ParseDuration(scrapeCache)
+	if err != nil {
+		log.WithFields(logrus.Fields{"ScrapeCache": scrapeCache, "Error": err}).
+			Fatal("Failed to parse scrapeCache")
 	}
-	client = starlink.NewDeviceClient(conn)
-	defer conn.Close()
 
-	log.Info("GRPC Connected to Dishy")
+	// Background collectors run until shutdown
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
 
-	// Prepare Prometheus
-	go promInit()
+	// Remote write per-second history if enabled
+	if remoteWriter, err = newRemoteWrite(); err != nil {
+		log.WithField("Error", err).Fatal("Invalid remote write configuration")
+	}
+	if remoteWriter != nil {
+		go remoteWriter.run(ctx)
+	}
+
+	// Close idle /probe connections
+	go reapProbeConns(ctx)
+
+	// Connect, retrying with backoff in the background
+	conn, err := dialConf.dial()
+	if err != nil {
+		log.WithFields(logrus.Fields{"Endpoint": host, "Error": err}).
+			Fatal("Failed to connect to dishy")
+	}
+	dishy = newDishDevice(conn, dishyMetrics)
+	dishy.primary = true
+	client = dishy.client
+	defer conn.Close()
+
+	// Watch for (re)connects
+	connected := make(chan struct{}, 1)
+	go watchConnState(conn, "Dishy", promDishyConnState, connected)
 
-	// Get Info
-	dishy.Request = &starlink.Request_GetDeviceInfo{}
-	info, _ := getRequest()
+	// Router is optional, updates skip it until connected
+	if err := routerInit(); err != nil {
+		log.WithFields(logrus.Fields{"Endpoint": routerHost, "Error": err}).
+			Fatal("Invalid router configuration")
+	}
+	if err := wifiScanInit(ctx); err != nil {
+		log.WithField("Error", err).Fatal("Invalid router scan configuration")
+	}
 
-	// Prepare Dishy info labels
-	dishyLabels = prometheus.Labels{
-		"id":                   info.GetGetDeviceInfo().DeviceInfo.Id,
-		"country_code":         info.GetGetDeviceInfo().DeviceInfo.CountryCode,
-		"hardware_version":     info.GetGetDeviceInfo().DeviceInfo.HardwareVersion,
-		"software_version":     info.GetGetDeviceInfo().DeviceInfo.SoftwareVersion,
-		"manufactured_version": info.GetGetDeviceInfo().DeviceInfo.ManufacturedVersion,
+	// Obstruction map on its own, slower, interval
+	if err := obstructionInit(ctx); err != nil {
+		log.WithField("Error", err).Fatal("Invalid obstruction map configuration")
 	}
 
-	// Dump some stats if debug
-	if log.IsLevelEnabled(logrus.DebugLevel) {
-		dumpData()
+	// Scheduled speed tests
+	if err := speedtestInit(ctx); err != nil {
+		log.WithField("Error", err).Fatal("Invalid speed test configuration")
 	}
 
+	// Prepare Prometheus once clients exist, dialing doesn't block so this
+	// still serves before Dishy is reachable
+	registerMetrics(cacheTTL)
+	go promInit()
+
 	// Handle death
-	die := make(chan os.Signal)
+	die := make(chan os.Signal, 1)
 	signal.Notify(die, syscall.SIGINT, syscall.SIGTERM)
 
 	// Parse duration and create a ticker
@@ -255,9 +474,17 @@ func main() {
 	ticker := time.NewTicker(duration)
 	defer ticker.Stop()
 
-	log.Info("Serving metrics, entering update loop")
+	// Scrapes drive updates in scrape mode, never tick
+	tick := ticker.C
+	if mode == modeScrape {
+		tick = nil
+	}
 
-	UpdateMetrics() // Don't wait for the first Tick
+	log.WithField("Mode", mode).Info("Serving metrics, entering update loop")
+
+	if mode == modeTicker {
+		UpdateMetrics() // Don't wait for the first Tick
+	}
 
 	// Update forever
 	for {
@@ -266,7 +493,14 @@ func main() {
 			log.Warn("Asked to die, waiting on goroutines...")
 			wg.Wait()
 			os.Exit(0)
-		case <-ticker.C:
+		case <-connected:
+			// Device may have been replaced or upgraded while away
+			dishy.refreshLabels()
+			// Dump some stats if debug
+			if log.IsLevelEnabled(logrus.DebugLevel) {
+				dumpData()
+			}
+		case <-tick:
 			UpdateMetrics()
 		}
 	}
@@ -275,31 +509,32 @@ func main() {
 
 // Dump some info if level is high enough
 func dumpData() {
+	ctx := context.Background()
+
 	// Device Info
-	dishy.Request = &starlink.Request_GetDeviceInfo{}
-	info, _ := getRequest()
-	log.Printf("Info: %+v", info.GetGetDeviceInfo().DeviceInfo)
+	info, _ := client.GetDeviceInfo(ctx)
+	log.Printf("Info: %+v", info)
 
 	// Status
-	dishy.Request = &starlink.Request_GetStatus{}
-	status, _ := getRequest()
-	log.Debugf("GPS: %+v", status.GetDishGetStatus().GetGpsStats())
-	log.Debugf("DishObstructed: %+v", status.GetDishGetStatus().GetObstructionStats().GetCurrentlyObstructed())
-	log.Debugf("DeviceAlerts: %v", status.GetDishGetStatus().GetAlerts())
-	log.Debugf("MotorsStuck: %v", status.GetDishGetStatus().Alerts.GetMotorsStuck())
-	log.Debugf("ThermalThrottle: %v", status.GetDishGetStatus().Alerts.GetThermalThrottle())
-	log.Debugf("ThermalShutdown: %v", status.GetDishGetStatus().Alerts.GetThermalShutdown())
-	log.Debugf("PopPingDropRate: %+v", status.GetDishGetStatus().PopPingDropRate)
-	log.Debugf("CurrentElevation: %+v", status.GetDishGetStatus().GetBoresightElevationDeg())
-	log.Debugf("CurrentAzimuth: %+v", status.GetDishGetStatus().GetBoresightAzimuthDeg())
-	log.Debugf("Outage: %+v", status.GetDishGetStatus().GetOutage())
+	status, _ := client.GetStatus(ctx)
+	log.Debugf("GPS: %+v", status.GetGpsStats())
+	log.Debugf("DishObstructed: %+v", status.GetObstructionStats().GetCurrentlyObstructed())
+	log.Debugf("DeviceAlerts: %v", status.GetAlerts())
+	log.Debugf("MotorsStuck: %v", status.GetAlerts().GetMotorsStuck())
+	log.Debugf("ThermalThrottle: %v", status.GetAlerts().GetThermalThrottle())
+	log.Debugf("ThermalShutdown: %v", status.GetAlerts().GetThermalShutdown())
+	log.Debugf("PopPingDropRate: %+v", status.GetPopPingDropRate())
+	log.Debugf("CurrentElevation: %+v", status.GetBoresightElevationDeg())
+	log.Debugf("CurrentAzimuth: %+v", status.GetBoresightAzimuthDeg())
+	log.Debugf("Outage: %+v", status.GetOutage())
 
 	// History
-	dishy.Request = &starlink.Request_GetHistory{}
-	history, _ := getRequest()
-	log.Debugf("PopPingDropRateLast20: %+v", history.GetDishGetHistory().PopPingDropRate[len(history.GetDishGetHistory().PopPingDropRate)-20:])
-	outages := history.GetDishGetHistory().GetOutages()
-	log.Debugf("Current %+v", history.GetDishGetHistory().GetCurrent())
+	history, _ := client.GetHistory(ctx)
+	if drops := history.GetPopPingDropRate(); len(drops) >= 20 {
+		log.Debugf("PopPingDropRateLast20: %+v", drops[len(drops)-20:])
+	}
+	outages := history.GetOutages()
+	log.Debugf("Current %+v", history.GetCurrent())
 	log.Debug("Outages:")
 	for i := 0; i < len(outages); i++ {
 		outage := outages[i]
@@ -308,38 +543,10 @@ func dumpData() {
 	}
 
 	// Config
-	dishy.Request = &starlink.Request_DishGetConfig{}
-	conf, _ := getRequest()
+	conf, _ := client.GetConfig(ctx)
 	log.Debugf("Config %+v", conf)
 }
 
-// Generic request getter
-func getRequest() (*starlink.Response, error) {
-	// Prepare request context
-	ctx, cancel := context.WithTimeout(context.Background(), timeout*time.Second)
-	defer cancel()
-
-	t1 := time.Now()
-	resp, err := client.Handle(ctx, dishy) // Make request
-
-	promDishyGRPCTime.Observe(float64(time.Now().Sub(t1).Milliseconds()))
-
-	if err != nil {
-		promDishyFailing.Set(1)
-		promDishyFailures.Inc()
-		log.WithFields(logrus.Fields{
-			"Request": dishy.Request,
-			"Error":   err,
-		}).Error("Unable to request data from Dishy")
-	} else {
-		promDishyFailing.Set(0)
-	}
-
-	promDishyRequests.Inc()
-
-	return resp, err
-}
-
 // Check for log level in config, use default if not found
 func setLogLevel() {
 	switch logLevel {
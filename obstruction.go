@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Obstruction map settings
+var (
+	obstructionInterval  string  = "5m" // Obstruction map update interval, 0 disables
+	obstructionThreshold float64 = 0.5  // Cells with SNR below this are obstructed
+	obstructionSectors   int     = 12   // Number of azimuth sectors
+)
+
+func init() {
+	flag.StringVar(&obstructionInterval, "obstructionInterval", obstructionInterval, "Obstruction map update interval, 0 to disable (go time.Duration)")
+	flag.Float64Var(&obstructionThreshold, "obstructionThreshold", obstructionThreshold, "Obstruction map cells with SNR below this (0-1) are considered obstructed")
+	flag.IntVar(&obstructionSectors, "obstructionSectors", obstructionSectors, "Number of azimuth sectors for obstruction map metrics")
+}
+
+// Latest obstruction map, served over HTTP
+var (
+	obstructionMap     *obstructionGrid
+	obstructionMapLock sync.RWMutex
+)
+
+// Dishy obstruction map. Cells are row-major with north at the top and
+// zenith in the center, SNR is 0-1 or -1 where the dish has no data.
+type obstructionGrid struct {
+	Rows    int       `json:"rows"`
+	Cols    int       `json:"cols"`
+	SNR     []float32 `json:"snr"`
+	Updated time.Time `json:"updated"`
+}
+
+func newObstructionGrid(resp *starlink.DishGetObstructionMapResponse) (*obstructionGrid, error) {
+	grid := &obstructionGrid{
+		Rows:    int(resp.GetNumRows()),
+		Cols:    int(resp.GetNumCols()),
+		SNR:     resp.GetSnr(),
+		Updated: time.Now(),
+	}
+	if grid.Rows*grid.Cols == 0 || len(grid.SNR) != grid.Rows*grid.Cols {
+		return nil, fmt.Errorf("obstruction map is %dx%d with %d cells", grid.Rows, grid.Cols, len(grid.SNR))
+	}
+	return grid, nil
+}
+
+// Returns the SNR of a cell
+func (g *obstructionGrid) at(row, col int) float32 {
+	return g.SNR[row*g.Cols+col]
+}
+
+// Returns the azimuth in degrees (0 north, clockwise) of a cell from the center
+func (g *obstructionGrid) azimuth(row, col int) float64 {
+	dx := float64(col) - float64(g.Cols-1)/2
+	dy := float64(g.Rows-1)/2 - float64(row)
+	az := math.Atan2(dx, dy) * 180 / math.Pi
+	if az < 0 {
+		az += 360
+	}
+	return az
+}
+
+// Starts the periodic obstruction map collector if enabled
+func obstructionInit(ctx context.Context) error {
+	interval, err := parseDurationFlag("obstructionInterval", obstructionInterval)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+	if obstructionSectors < 1 || obstructionSectors > 360 {
+		return fmt.Errorf("invalid -obstructionSectors %d, expected 1-360", obstructionSectors)
+	}
+
+	http.HandleFunc("/obstruction/map.json", serveObstructionJSON)
+	http.HandleFunc("/obstruction/map.png", serveObstructionPNG)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			updateObstructionMetrics()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Fetches the obstruction map and updates summary metrics
+func updateObstructionMetrics() {
+	resp, err := client.GetObstructionMap(context.Background())
+	if err != nil {
+		return
+	}
+	grid, err := newObstructionGrid(resp)
+	if err != nil {
+		log.WithField("Error", err).Warn("Ignoring invalid obstruction map")
+		return
+	}
+
+	// Summarize cells, per sector and overall
+	sectorWidth := 360 / float64(obstructionSectors)
+	sectorData := make([]float64, obstructionSectors)
+	sectorObstructed := make([]float64, obstructionSectors)
+	var withData, obstructed float64
+	for row := 0; row < grid.Rows; row++ {
+		for col := 0; col < grid.Cols; col++ {
+			snr := grid.at(row, col)
+			if snr < 0 {
+				continue
+			}
+			sector := int(grid.azimuth(row, col)/sectorWidth) % obstructionSectors
+			withData++
+			sectorData[sector]++
+			if float64(snr) < obstructionThreshold {
+				obstructed++
+				sectorObstructed[sector]++
+			}
+		}
+	}
+
+	promDishyObstructionMapData.Set(withData / float64(len(grid.SNR)))
+	promDishyObstructionMapObstructed.Set(fractionOf(obstructed, withData))
+	for i := range sectorData {
+		promDishyObstructionMapSector.
+			WithLabelValues(strconv.FormatFloat(float64(i)*sectorWidth, 'f', -1, 64)).
+			Set(fractionOf(sectorObstructed[i], sectorData[i]))
+	}
+
+	log.WithFields(logrus.Fields{
+		"Rows":       grid.Rows,
+		"Cols":       grid.Cols,
+		"WithData":   withData,
+		"Obstructed": obstructed,
+	}).Trace("Updated obstruction map")
+
+	obstructionMapLock.Lock()
+	obstructionMap = grid
+	obstructionMapLock.Unlock()
+}
+
+// Returns the latest obstruction map, writing an error if there is none
+func latestObstructionMap(w http.ResponseWriter) *obstructionGrid {
+	obstructionMapLock.RLock()
+	defer obstructionMapLock.RUnlock()
+	if obstructionMap == nil {
+		http.Error(w, "obstruction map not yet available", http.StatusServiceUnavailable)
+	}
+	return obstructionMap
+}
+
+// Serves the raw obstruction map as JSON
+func serveObstructionJSON(w http.ResponseWriter, r *http.Request) {
+	grid := latestObstructionMap(w)
+	if grid == nil {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(grid); err != nil {
+		log.WithField("Error", err).Debug("Failed to write obstruction map")
+	}
+}
+
+// Serves the obstruction map as a PNG heatmap, red obstructed through green
+// clear, transparent without data. Pixels per cell may be set with ?scale=
+func serveObstructionPNG(w http.ResponseWriter, r *http.Request) {
+	grid := latestObstructionMap(w)
+	if grid == nil {
+		return
+	}
+
+	scale := 4
+	if s := r.URL.Query().Get("scale"); s != "" {
+		var err error
+		if scale, err = strconv.Atoi(s); err != nil || scale < 1 || scale > 16 {
+			http.Error(w, "scale must be 1-16", http.StatusBadRequest)
+			return
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, grid.Cols*scale, grid.Rows*scale))
+	for row := 0; row < grid.Rows; row++ {
+		for col := 0; col < grid.Cols; col++ {
+			c := snrColor(grid.at(row, col))
+			for y := row * scale; y < (row+1)*scale; y++ {
+				for x := col * scale; x < (col+1)*scale; x++ {
+					img.SetNRGBA(x, y, c)
+				}
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	if err := png.Encode(w, img); err != nil {
+		log.WithField("Error", err).Debug("Failed to write obstruction map")
+	}
+}
+
+// Maps SNR to a red-yellow-green color
+func snrColor(snr float32) color.NRGBA {
+	if snr < 0 {
+		return color.NRGBA{}
+	}
+	if snr > 1 {
+		snr = 1
+	}
+	if snr < 0.5 {
+		return color.NRGBA{R: 255, G: uint8(510 * snr), A: 255}
+	}
+	return color.NRGBA{R: uint8(510 * (1 - snr)), G: 255, A: 255}
+}
+
+// Exports per-wedge obstruction fractions labeled by index and start bearing
+func (d *dishDevice) updateWedgeMetrics(stats *starlink.DishObstructionStats) {
+	wedges := stats.GetWedgeFractionObstructed()
+	abs := stats.GetWedgeAbsFractionObstructed()
+	if len(wedges) != d.wedges {
+		d.promDishyWedgeFractionObstructed.Reset()
+		d.promDishyWedgeAbsFractionObstructed.Reset()
+		d.wedges = len(wedges)
+	}
+
+	width := 360 / float64(len(wedges))
+	for i, fraction := range wedges {
+		wedge := strconv.Itoa(i)
+		bearing := strconv.FormatFloat(float64(i)*width, 'f', -1, 64)
+		d.promDishyWedgeFractionObstructed.WithLabelValues(wedge, bearing).Set(float64(fraction))
+		if i < len(abs) {
+			d.promDishyWedgeAbsFractionObstructed.WithLabelValues(wedge, bearing).Set(float64(abs[i]))
+		}
+	}
+}
+
+// Returns n/d, or 0 when d is 0
+func fractionOf(n, d float64) float64 {
+	if d == 0 {
+		return 0
+	}
+	return n / d
+}
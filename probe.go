@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// Probe modules and their default GRPC ports
+const (
+	moduleDish   = "dish"
+	moduleRouter = "router"
+)
+
+var probeDefaultPorts = map[string]string{
+	moduleDish:   "9200",
+	moduleRouter: "9000",
+}
+
+// Pooled connections are closed after this long unused
+const probeIdleTimeout = 10 * time.Minute
+
+// Default probe timeout when Prometheus doesn't send one
+const probeTimeout = 10 * time.Second
+
+// Maximum pooled probe connections, probes of new targets fail once reached
+var probeMaxConns int = 16
+
+func init() {
+	flag.IntVar(&probeMaxConns, "probeMaxConns", probeMaxConns, "Maximum number of /probe targets to hold connections to")
+}
+
+var errProbeConnsFull = errors.New("probe connection limit reached")
+
+// Identifies a pooled connection, a target probed with both modules gets two
+type probeKey struct {
+	target string
+	module string
+}
+
+// Pooled probe connections by target and module
+var (
+	probeConns     = make(map[probeKey]*probeConn)
+	probeConnsLock sync.Mutex
+)
+
+// A pooled connection to a probe target, with the module's device and the
+// registry its updaters write to. Both outlive a single probe so counters
+// and history cursors advance from the previous probe.
+type probeConn struct {
+	conn     *grpc.ClientConn
+	client   *deviceClient
+	updaters map[string]func(context.Context)
+	registry *prometheus.Registry
+	lock     sync.Mutex // Held for a whole probe so polls of one device don't overlap
+	lastUsed time.Time
+}
+
+// Returns a pooled connection to target, dialing if needed
+func getProbeConn(target, module string) (*probeConn, error) {
+	probeConnsLock.Lock()
+	defer probeConnsLock.Unlock()
+
+	key := probeKey{target: target, module: module}
+	if pc, ok := probeConns[key]; ok {
+		pc.lastUsed = time.Now()
+		return pc, nil
+	}
+	if len(probeConns) >= probeMaxConns {
+		return nil, errProbeConnsFull
+	}
+
+	dialConf, err := newDialConfig(target)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dialConf.dial()
+	if err != nil {
+		return nil, err
+	}
+	pc := &probeConn{conn: conn, registry: prometheus.NewRegistry(), lastUsed: time.Now()}
+	switch module {
+	case moduleDish:
+		dev := newDishDevice(conn, newDishMetricSet(promauto.With(pc.registry)))
+		pc.client, pc.updaters = dev.client, dev.updaters()
+	case moduleRouter:
+		dev := newRouterDevice(conn, newRouterMetricSet(promauto.With(pc.registry)))
+		pc.client, pc.updaters = dev.client, dev.updaters()
+	}
+	pc.client.name = target
+	probeConns[key] = pc
+	promProbeConns.Set(float64(len(probeConns)))
+	log.WithFields(logrus.Fields{"Target": target, "Module": module}).Debug("Opened probe connection")
+
+	return pc, nil
+}
+
+// Closes pooled connections that haven't been used recently
+func reapProbeConns(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		probeConnsLock.Lock()
+		for key, pc := range probeConns {
+			if time.Since(pc.lastUsed) > probeIdleTimeout {
+				log.WithFields(logrus.Fields{"Target": key.target, "Module": key.module}).Debug("Closing idle probe connection")
+				pc.conn.Close()
+				delete(probeConns, key)
+			}
+		}
+		promProbeConns.Set(float64(len(probeConns)))
+		probeConnsLock.Unlock()
+	}
+}
+
+// Blocks until conn is ready or ctx is done
+func waitReady(ctx context.Context, conn *grpc.ClientConn) error {
+	for {
+		state := conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Idle:
+			conn.Connect()
+		}
+		if !conn.WaitForStateChange(ctx, state) {
+			return fmt.Errorf("connection %s: %w", connStateLabel(state), ctx.Err())
+		}
+	}
+}
+
+// Serves /probe?target=host[:port]&module=dish|router
+func serveProbe(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Query().Get("target")
+	module := r.URL.Query().Get("module")
+	if module == "" {
+		module = moduleDish
+	}
+	port, ok := probeDefaultPorts[module]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown module %q, expected %s or %s", module, moduleDish, moduleRouter), http.StatusBadRequest)
+		return
+	}
+	if target == "" {
+		http.Error(w, "target parameter is required", http.StatusBadRequest)
+		return
+	}
+	if _, _, err := net.SplitHostPort(target); err != nil {
+		target = net.JoinHostPort(target, port)
+	}
+
+	// Leave some of Prometheus' scrape timeout for the response
+	timeout := probeTimeout
+	if s := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds"); s != "" {
+		if secs, err := strconv.ParseFloat(s, 64); err == nil && secs > 1 {
+			timeout = time.Duration((secs - 0.5) * float64(time.Second))
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	start := time.Now()
+	registry := prometheus.NewRegistry()
+	gatherers := prometheus.Gatherers{registry}
+	logger := log.WithFields(logrus.Fields{"Target": target, "Module": module})
+
+	var success bool
+	if pc, err := getProbeConn(target, module); err != nil {
+		logger.WithField("Error", err).Warn("Probe failed to connect")
+	} else {
+		// Held until the response is written so it reflects this probe's poll
+		pc.lock.Lock()
+		defer pc.lock.Unlock()
+		if success = pc.probe(ctx, logger); success {
+			gatherers = append(gatherers, pc.registry)
+		}
+	}
+
+	var successVal float64
+	if success {
+		successVal = 1
+	}
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "probe_success",
+		Help: "Whether the probe succeeded",
+	}, func() float64 { return successVal }))
+	registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "probe_duration_seconds",
+		Help: "Time taken for the probe to complete",
+	}, func() float64 { return time.Since(start).Seconds() }))
+
+	promProbes.WithLabelValues(module, strconv.FormatBool(success)).Inc()
+	promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+}
+
+// Runs the module's updaters against the target, the probe succeeds if none
+// of its requests failed. Callers must hold pc.lock.
+func (pc *probeConn) probe(ctx context.Context, logger *logrus.Entry) bool {
+	if err := waitReady(ctx, pc.conn); err != nil {
+		logger.WithField("Error", err).Warn("Probe target not ready")
+		return false
+	}
+
+	before := counterValue(pc.client.metrics.failures)
+	runUpdaters(ctx, pc.updaters)
+	if failures := counterValue(pc.client.metrics.failures) - before; failures > 0 {
+		logger.WithField("Failures", failures).Warn("Probe failed")
+		return false
+	}
+	return true
+}
+
+// Returns the current value of a counter
+func counterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
@@ -9,18 +10,15 @@ import (
 )
 
 var (
-	// Prepare Prometheus
+	// Prepare Prometheus, metrics are registered by registerMetrics
 	prom    = prometheus.NewRegistry()
-	metrics = promauto.With(prom)
+	metrics = promauto.With(&dishyCollectors)
+
+	// Metric sets for the exporter's own devices, probes create their own
+	dishyMetrics  = newDishMetricSet(metrics)
+	routerMetrics = newRouterMetricSet(metrics)
 
 	// InternalMetrics
-	promDishyGRPCTime = metrics.NewHistogram(prometheus.HistogramOpts{
-		Namespace: "starlink",
-		Subsystem: "exporter",
-		Name:      "grpc_time",
-		Help:      "Time spend interacting with Dishy GRPC",
-		Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
-	})
 	promDishyUpdateTime = metrics.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "starlink",
 		Subsystem: "exporter",
@@ -34,164 +32,1175 @@ var (
 		Name:      "updates",
 		Help:      "Number of Dishy updates",
 	})
-	promDishyFailures = metrics.NewCounter(prometheus.CounterOpts{
+	promDishyConnState = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "exporter",
-		Name:      "failures",
-		Help:      "Number of Dishy request failures",
-	})
-	promDishyRequests = metrics.NewCounter(prometheus.CounterOpts{
+		Name:      "connection_state",
+		Help:      "GRPC connection state to Dishy, 1 for the current state",
+	}, []string{"state"})
+	promRemoteWriteSent = metrics.NewCounter(prometheus.CounterOpts{
 		Namespace: "starlink",
 		Subsystem: "exporter",
-		Name:      "requests",
-		Help:      "Number of Dishy requests",
+		Name:      "remote_write_samples_sent",
+		Help:      "Number of history samples sent via remote write",
 	})
-	promDishyFailing = metrics.NewGauge(prometheus.GaugeOpts{
+	promRemoteWriteDropped = metrics.NewCounter(prometheus.CounterOpts{
 		Namespace: "starlink",
 		Subsystem: "exporter",
-		Name:      "failing",
-		Help:      "Boolean indicator if requests to Dishy are failing",
+		Name:      "remote_write_samples_dropped",
+		Help:      "Number of history samples dropped before they could be sent",
 	})
-
-	// Dishy Info Metrics
-	promDishyBootcount = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	promRemoteWritePending = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "bootcount",
-		Help:      "Dishy Boot Count",
-	}, []string{"id", "hardware_version", "software_version", "manufactured_version", "country_code"})
-	promDishyUptimeS = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "exporter",
+		Name:      "remote_write_samples_pending",
+		Help:      "Number of history samples waiting to be sent",
+	})
+	promRemoteWriteFailures = metrics.NewCounter(prometheus.CounterOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "uptime_s",
-		Help:      "Uptime of Dishy",
+		Subsystem: "exporter",
+		Name:      "remote_write_failures",
+		Help:      "Number of failed remote write requests",
 	})
-
-	// Dishy GPS Metrics
-	promDishyGPSValid = metrics.NewGauge(prometheus.GaugeOpts{
+	// Obstruction Map Metrics
+	promDishyObstructionMapData = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "gps_valid",
-		Help:      "Boolean indicator for GPS Valid",
+		Name:      "obstruction_map_data_fraction",
+		Help:      "Fraction of obstruction map cells with data",
 	})
-	promDishyGPSSats = metrics.NewGauge(prometheus.GaugeOpts{
+	promDishyObstructionMapObstructed = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "gps_sats",
-		Help:      "Number of available GPS Satellites",
+		Name:      "obstruction_map_obstructed_fraction",
+		Help:      "Fraction of obstruction map cells with data below the SNR threshold",
 	})
-
-	// Alerts
-	promDishyAlertStatus = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	promDishyObstructionMapSector = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "alert_status",
-		Help:      "Status of Alerts",
-	}, []string{"alert"})
-	promDishyObstructed = metrics.NewGauge(prometheus.GaugeOpts{
+		Name:      "obstruction_map_sector_obstructed_fraction",
+		Help:      "Fraction of obstruction map cells with data below the SNR threshold by azimuth sector start",
+	}, []string{"azimuth"})
+	// Router Exporter Metrics
+	promRouterConnState = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "obstructed",
-		Help:      "Boolean, dishy is obstructed",
-	})
-	promDishyFractionObstructed = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "exporter",
+		Name:      "router_connection_state",
+		Help:      "GRPC connection state to the router, 1 for the current state",
+	}, []string{"state"})
+	// Router Neighbor Scan Metrics
+	promRouterNeighborNetworks = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "fraction_obstructed",
-		Help:      "Percent Obstructed",
-	})
-	promDishyAvgObstructedDurationS = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "router",
+		Name:      "neighbor_networks",
+		Help:      "Number of neighboring networks by band and channel",
+	}, []string{"band", "channel"})
+	promRouterNeighborStrongest = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "avg_prolonged_obstruction_duration_s",
-		Help:      "Boolean, average duration of obstruction",
-	})
-	promDishyOutage = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "router",
+		Name:      "neighbor_strongest_signal_dbm",
+		Help:      "Signal of the strongest neighboring network by band and channel",
+	}, []string{"band", "channel"})
+	promRouterRecommendedChannel = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "outage",
-		Help:      "Boolean, current Dishy outage status",
-	})
-	promDishyAlerts = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "router",
+		Name:      "recommended_channel",
+		Help:      "Channel with the least neighbor interference by band",
+	}, []string{"band"})
+	// Probe Metrics
+	promProbes = metrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "alerts",
-		Help:      "Number of current alerts",
-	})
-	promDishyPopPingDropRate = metrics.NewGauge(prometheus.GaugeOpts{
+		Subsystem: "exporter",
+		Name:      "probes",
+		Help:      "Number of /probe requests by module and success",
+	}, []string{"module", "success"})
+	promProbeConns = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
-		Subsystem: "dishy",
-		Name:      "pop_ping_drop_rate",
-		Help:      "Current pop ping drop rate",
+		Subsystem: "exporter",
+		Name:      "probe_connections",
+		Help:      "Number of pooled probe connections",
 	})
-	promDishyPopPingLatencyMs = metrics.NewGauge(prometheus.GaugeOpts{
+	// Dishy Geofence Metrics
+	promDishyGeofenceInside = metrics.NewGaugeVec(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "pop_ping_latency_ms",
-		Help:      "Current pop ping latency",
-	})
-	promDishyDLTputBps = metrics.NewGauge(prometheus.GaugeOpts{
+		Name:      "geofence_inside",
+		Help:      "Boolean, dishy is inside the geofence",
+	}, []string{"fence"})
+	promDishyGeofenceTransitions = metrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "downlink_throughput_bps",
-		Help:      "Current downlink throughput in bits persecond",
-	})
-	promDishyULTputBps = metrics.NewGauge(prometheus.GaugeOpts{
+		Name:      "geofence_transitions",
+		Help:      "Number of geofence enters and exits",
+	}, []string{"fence", "direction"})
+	promDishyGeofenceEvaluated = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "uplink_throughput_bps",
-		Help:      "Current uplink throughput in bits persecond",
+		Name:      "geofence_evaluated_timestamp",
+		Help:      "Unix time geofences were last evaluated against a location, geofence_inside is stale if this stops advancing",
 	})
-	promDishyAzimuthDeg = metrics.NewGauge(prometheus.GaugeOpts{
+	// Dishy Speed Test Metrics
+	promDishySpeedtestDownloadMbps = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "boresight_azimuth_deg",
-		Help:      "Boresight azimum in degrees",
+		Name:      "speedtest_download_mbps",
+		Help:      "Peak download throughput of the last successful speed test",
 	})
-	promDishyElevationDeg = metrics.NewGauge(prometheus.GaugeOpts{
+	promDishySpeedtestUploadMbps = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "boresight_elevation_deg",
-		Help:      "Boresight elevation in degrees",
+		Name:      "speedtest_upload_mbps",
+		Help:      "Peak upload throughput of the last successful speed test",
 	})
-	promDishyEthSpeedMbps = metrics.NewGauge(prometheus.GaugeOpts{
+	promDishySpeedtestLatencyMs = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "eth_speed_mbps",
-		Help:      "Ethernet speed in mbps",
+		Name:      "speedtest_latency_ms",
+		Help:      "Latency of the last successful legacy speed test",
 	})
-
-	// Outage Metrics
-	promDishyOutageHistogram = metrics.NewHistogram(prometheus.HistogramOpts{
+	promDishySpeedtestLastSuccess = metrics.NewGauge(prometheus.GaugeOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "outage_times",
-		Help:      "Histogram of outage times",
-		Buckets:   prometheus.ExponentialBucketsRange(0.25, 3600, 15),
+		Name:      "speedtest_last_success_timestamp_seconds",
+		Help:      "Unix time the last successful speed test finished",
 	})
-	promDishyOutages = metrics.NewGaugeVec(prometheus.GaugeOpts{
+	promDishySpeedtestDuration = metrics.NewHistogram(prometheus.HistogramOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "outages",
-		Help:      "Number of recent outages",
-	}, []string{"cause"})
-	promDishyAvgOutageDuration = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "speedtest_duration_seconds",
+		Help:      "Time taken by speed tests",
+		Buckets:   prometheus.LinearBuckets(10, 10, 12),
+	})
+	promDishySpeedtests = metrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "outage_duration_sec_avg",
-		Help:      "Avg Outage Duration",
-	}, []string{"cause"})
-	promDishySumOutageDuration = metrics.NewGaugeVec(prometheus.GaugeOpts{
+		Name:      "speedtests",
+		Help:      "Number of speed tests run by source and result",
+	}, []string{"source", "result"})
+	promDishySpeedtestSkipped = metrics.NewCounterVec(prometheus.CounterOpts{
 		Namespace: "starlink",
 		Subsystem: "dishy",
-		Name:      "outage_duration_sec_sum",
-		Help:      "Total Outage Duration",
-	}, []string{"cause"})
+		Name:      "speedtests_skipped",
+		Help:      "Number of scheduled speed tests skipped by reason",
+	}, []string{"reason"})
 )
 
+// Metrics written by Dishy updaters
+type dishMetricSet struct {
+	// Request Metrics
+	promDishyGRPCTime prometheus.Histogram
+	promDishyFailures prometheus.Counter
+	promDishyRequests prometheus.Counter
+	promDishyFailing  prometheus.Gauge
+	// Dishy Info Metrics
+	promDishyInfo      *prometheus.GaugeVec
+	promDishyBootcount *prometheus.GaugeVec
+	promDishyUptimeS   prometheus.Gauge
+	// Dishy GPS Metrics
+	promDishyGPSValid prometheus.Gauge
+	promDishyGPSSats  prometheus.Gauge
+	// Alerts
+	promDishyAlertStatus                *prometheus.GaugeVec
+	promDishyObstructed                 prometheus.Gauge
+	promDishyFractionObstructed         prometheus.Gauge
+	promDishyObstructionValidS          prometheus.Gauge
+	promDishyAvgObstructedIntervalS     prometheus.Gauge
+	promDishyAvgObstructedValid         prometheus.Gauge
+	promDishyWedgeFractionObstructed    *prometheus.GaugeVec
+	promDishyWedgeAbsFractionObstructed *prometheus.GaugeVec
+	promDishyAvgObstructedDurationS     prometheus.Gauge
+	promDishyOutage                     prometheus.Gauge
+	promDishyAlerts                     prometheus.Gauge
+	promDishyPopPingDropRate            prometheus.Gauge
+	promDishyPopPingLatencyMs           prometheus.Gauge
+	promDishyDLTputBps                  prometheus.Gauge
+	promDishyULTputBps                  prometheus.Gauge
+	promDishyAzimuthDeg                 prometheus.Gauge
+	promDishyElevationDeg               prometheus.Gauge
+	promDishyEthSpeedMbps               prometheus.Gauge
+	// Outage Metrics
+	promDishyOutageHistogram   prometheus.Histogram
+	promDishyOutages           *prometheus.GaugeVec
+	promDishyAvgOutageDuration *prometheus.GaugeVec
+	promDishySumOutageDuration *prometheus.GaugeVec
+	// Per-second History Metrics
+	promDishyHistorySamples         prometheus.Counter
+	promDishyHistorySamplesLost     prometheus.Counter
+	promDishyHistoryPingDropRate    prometheus.Histogram
+	promDishyHistoryPingDropSeconds prometheus.Counter
+	promDishyHistoryPingLatencyMs   prometheus.Histogram
+	promDishyHistoryDLTputBps       prometheus.Histogram
+	promDishyHistoryULTputBps       prometheus.Histogram
+	// Transceiver Metrics
+	promDishyTransceiverSupported     prometheus.Gauge
+	promDishyTransceiverModState      *prometheus.GaugeVec
+	promDishyTransceiverDemodState    *prometheus.GaugeVec
+	promDishyTransceiverTxState       *prometheus.GaugeVec
+	promDishyTransceiverRxState       *prometheus.GaugeVec
+	promDishyTransceiverDishState     *prometheus.GaugeVec
+	promDishyTransceiverBlankingState *prometheus.GaugeVec
+	promDishyTransceiverFaults        *prometheus.GaugeVec
+	promDishyTransceiverModemAsicTemp prometheus.Gauge
+	promDishyTransceiverTxIfTemp      prometheus.Gauge
+	// Transceiver Telemetry Metrics
+	promDishyTelemAntennaPitch             prometheus.Gauge
+	promDishyTelemAntennaRoll              prometheus.Gauge
+	promDishyTelemAntennaRxTheta           prometheus.Gauge
+	promDishyTelemAntennaTrueHeading       prometheus.Gauge
+	promDishyTelemAntennaPointingMode      prometheus.Gauge
+	promDishyTelemRxChannel                prometheus.Gauge
+	promDishyTelemCellID                   prometheus.Gauge
+	promDishyTelemLmacSatelliteID          prometheus.Gauge
+	promDishyTelemTargetSatelliteID        prometheus.Gauge
+	promDishyTelemSecondsUntilSlotEnd      prometheus.Gauge
+	promDishyTelemCurrentSecondsOfSchedule prometheus.Gauge
+	promDishyTelemSnrDb                    prometheus.Gauge
+	promDishyTelemL1SnrAvgDb               prometheus.Gauge
+	promDishyTelemL1SnrMinDb               prometheus.Gauge
+	promDishyTelemL1SnrMaxDb               prometheus.Gauge
+	promDishyTelemWbRssiPeakMagDb          prometheus.Gauge
+	promDishyTelemCeRssiDb                 prometheus.Gauge
+	promDishyTelemGrantMcs                 prometheus.Gauge
+	promDishyTelemGrantSymbolsAvg          prometheus.Gauge
+	promDishyTelemDedGrant                 prometheus.Gauge
+	promDishyTelemEmaVelocity              *prometheus.GaugeVec
+	promDishyTelemProactiveSlotChanges     prometheus.Counter
+	promDishyTelemReactiveSlotChanges      prometheus.Counter
+	promDishyTelemSyncFailures             prometheus.Counter
+	promDishyTelemOutOfSeq                 prometheus.Counter
+	promDishyTelemUlmapDrops               prometheus.Counter
+	promDishyTelemLabelSwitchFailures      prometheus.Counter
+	promDishyTelemCellChanges              prometheus.Counter
+	promDishyTelemSatelliteChanges         prometheus.Counter
+	// Dishy Context Metrics
+	promDishyContextInfo             *prometheus.GaugeVec
+	promDishyContextCellID           prometheus.Gauge
+	promDishyContextPopRackID        prometheus.Gauge
+	promDishyContextBackupBeam       prometheus.Gauge
+	promDishyContextSecondsToSlotEnd prometheus.Gauge
+	promDishyContextPingDropRate     prometheus.Gauge
+	promDishyContextPingLatencyMs    prometheus.Gauge
+	promDishyContextSinceOutage      *prometheus.GaugeVec
+	promDishyContextCellChanges      prometheus.Counter
+	promDishyContextPopRackChanges   prometheus.Counter
+	// Dishy Boot Metrics
+	promDishyBootsByReason  *prometheus.GaugeVec
+	promDishyLastBootReason *prometheus.GaugeVec
+	promDishyReboots        *prometheus.CounterVec
+	// Dishy Location Metrics
+	promDishyLocationLatitude  prometheus.Gauge
+	promDishyLocationLongitude prometheus.Gauge
+	promDishyLocationAltitude  prometheus.Gauge
+	promDishyLocationGeohash   *prometheus.GaugeVec
+}
+
+// Creates Dishy metrics with a factory, registering them wherever it registers
+func newDishMetricSet(metrics promauto.Factory) *dishMetricSet {
+	return &dishMetricSet{
+		// Request Metrics
+		promDishyGRPCTime: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "grpc_time",
+			Help:      "Time spend interacting with Dishy GRPC",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		promDishyFailures: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "failures",
+			Help:      "Number of Dishy request failures",
+		}),
+		promDishyRequests: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "requests",
+			Help:      "Number of Dishy requests",
+		}),
+		promDishyFailing: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "failing",
+			Help:      "Boolean indicator if requests to Dishy are failing",
+		}),
+		// Dishy Info Metrics
+		promDishyInfo: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "info",
+			Help:      "Dishy info, always 1",
+		}, []string{"id", "hardware_version", "software_version", "manufactured_version", "country_code", "is_dev", "is_hitl", "software_partitions_equal", "utc_offset_s"}),
+		promDishyBootcount: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "bootcount",
+			Help:      "Dishy Boot Count",
+		}, []string{"id"}),
+		promDishyUptimeS: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "uptime_s",
+			Help:      "Uptime of Dishy",
+		}),
+		// Dishy GPS Metrics
+		promDishyGPSValid: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "gps_valid",
+			Help:      "Boolean indicator for GPS Valid",
+		}),
+		promDishyGPSSats: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "gps_sats",
+			Help:      "Number of available GPS Satellites",
+		}),
+		// Alerts
+		promDishyAlertStatus: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "alert_status",
+			Help:      "Status of Alerts",
+		}, []string{"alert"}),
+		promDishyObstructed: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "obstructed",
+			Help:      "Boolean, dishy is obstructed",
+		}),
+		promDishyFractionObstructed: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "fraction_obstructed",
+			Help:      "Percent Obstructed",
+		}),
+		promDishyObstructionValidS: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "obstruction_valid_s",
+			Help:      "Seconds of data behind obstruction statistics",
+		}),
+		promDishyAvgObstructedIntervalS: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "avg_prolonged_obstruction_interval_s",
+			Help:      "Average interval between prolonged obstructions",
+		}),
+		promDishyAvgObstructedValid: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "avg_prolonged_obstruction_valid",
+			Help:      "Boolean, prolonged obstruction averages are valid",
+		}),
+		promDishyWedgeFractionObstructed: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "wedge_fraction_obstructed",
+			Help:      "Fraction obstructed by wedge, bearing is the wedge start in degrees",
+		}, []string{"wedge", "bearing"}),
+		promDishyWedgeAbsFractionObstructed: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "wedge_abs_fraction_obstructed",
+			Help:      "Absolute fraction obstructed by wedge, bearing is the wedge start in degrees",
+		}, []string{"wedge", "bearing"}),
+		promDishyAvgObstructedDurationS: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "avg_prolonged_obstruction_duration_s",
+			Help:      "Boolean, average duration of obstruction",
+		}),
+		promDishyOutage: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "outage",
+			Help:      "Boolean, current Dishy outage status",
+		}),
+		promDishyAlerts: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "alerts",
+			Help:      "Number of current alerts",
+		}),
+		promDishyPopPingDropRate: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "pop_ping_drop_rate",
+			Help:      "Current pop ping drop rate",
+		}),
+		promDishyPopPingLatencyMs: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "pop_ping_latency_ms",
+			Help:      "Current pop ping latency",
+		}),
+		promDishyDLTputBps: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "downlink_throughput_bps",
+			Help:      "Current downlink throughput in bits persecond",
+		}),
+		promDishyULTputBps: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "uplink_throughput_bps",
+			Help:      "Current uplink throughput in bits persecond",
+		}),
+		promDishyAzimuthDeg: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "boresight_azimuth_deg",
+			Help:      "Boresight azimum in degrees",
+		}),
+		promDishyElevationDeg: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "boresight_elevation_deg",
+			Help:      "Boresight elevation in degrees",
+		}),
+		promDishyEthSpeedMbps: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "eth_speed_mbps",
+			Help:      "Ethernet speed in mbps",
+		}),
+		// Outage Metrics
+		promDishyOutageHistogram: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "outage_times",
+			Help:      "Histogram of outage times",
+			Buckets:   prometheus.ExponentialBucketsRange(0.25, 3600, 15),
+		}),
+		promDishyOutages: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "outages",
+			Help:      "Number of recent outages",
+		}, []string{"cause"}),
+		promDishyAvgOutageDuration: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "outage_duration_sec_avg",
+			Help:      "Avg Outage Duration",
+		}, []string{"cause"}),
+		promDishySumOutageDuration: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "outage_duration_sec_sum",
+			Help:      "Total Outage Duration",
+		}, []string{"cause"}),
+		// Per-second History Metrics
+		promDishyHistorySamples: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_samples",
+			Help:      "Number of per-second history samples ingested",
+		}),
+		promDishyHistorySamplesLost: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_samples_lost",
+			Help:      "Number of per-second history samples overwritten between polls",
+		}),
+		promDishyHistoryPingDropRate: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_pop_ping_drop_rate",
+			Help:      "Histogram of per-second pop ping drop rate",
+			Buckets:   []float64{0, 0.01, 0.05, 0.1, 0.25, 0.5, 0.75, 0.99, 1},
+		}),
+		promDishyHistoryPingDropSeconds: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_pop_ping_drop_seconds",
+			Help:      "Sum of per-second pop ping drop rate, seconds of full loss",
+		}),
+		promDishyHistoryPingLatencyMs: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_pop_ping_latency_ms",
+			Help:      "Histogram of per-second pop ping latency",
+			Buckets:   prometheus.ExponentialBucketsRange(10, 2000, 15),
+		}),
+		promDishyHistoryDLTputBps: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_downlink_throughput_bps",
+			Help:      "Histogram of per-second downlink throughput in bits per second",
+			Buckets:   prometheus.ExponentialBucketsRange(1e3, 1e9, 13),
+		}),
+		promDishyHistoryULTputBps: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "history_uplink_throughput_bps",
+			Help:      "Histogram of per-second uplink throughput in bits per second",
+			Buckets:   prometheus.ExponentialBucketsRange(1e3, 1e9, 13),
+		}),
+		// Transceiver Metrics
+		promDishyTransceiverSupported: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "supported",
+			Help:      "Boolean, firmware accepts transceiver status requests",
+		}),
+		promDishyTransceiverModState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "mod_state",
+			Help:      "Modulator state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverDemodState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "demod_state",
+			Help:      "Demodulator state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverTxState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "tx_state",
+			Help:      "Transmitter state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverRxState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "rx_state",
+			Help:      "Receiver state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverDishState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "dish_state",
+			Help:      "Dish state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverBlankingState: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "transmit_blanking_state",
+			Help:      "Transmit blanking state, 1 for the current state",
+		}, []string{"state"}),
+		promDishyTransceiverFaults: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "fault_status",
+			Help:      "Status of transceiver faults",
+		}, []string{"fault"}),
+		promDishyTransceiverModemAsicTemp: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "modem_asic_temp",
+			Help:      "Modem ASIC temperature",
+		}),
+		promDishyTransceiverTxIfTemp: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "tx_if_temp",
+			Help:      "Transmit IF temperature",
+		}),
+		// Transceiver Telemetry Metrics
+		promDishyTelemAntennaPitch: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "antenna_pitch",
+			Help:      "Antenna pitch in degrees",
+		}),
+		promDishyTelemAntennaRoll: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "antenna_roll",
+			Help:      "Antenna roll in degrees",
+		}),
+		promDishyTelemAntennaRxTheta: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "antenna_rx_theta",
+			Help:      "Antenna receive theta in degrees",
+		}),
+		promDishyTelemAntennaTrueHeading: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "antenna_true_heading",
+			Help:      "Antenna true heading in degrees",
+		}),
+		promDishyTelemAntennaPointingMode: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "antenna_pointing_mode",
+			Help:      "Antenna pointing mode",
+		}),
+		promDishyTelemRxChannel: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "rx_channel",
+			Help:      "Receive channel",
+		}),
+		promDishyTelemCellID: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "cell_id",
+			Help:      "Current cell ID from transceiver telemetry, starlink_dishy_context_cell_id is collected without -transceiverTelemetry",
+		}),
+		promDishyTelemLmacSatelliteID: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "lmac_satellite_id",
+			Help:      "Current LMAC satellite ID",
+		}),
+		promDishyTelemTargetSatelliteID: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "target_satellite_id",
+			Help:      "Current target satellite ID",
+		}),
+		promDishyTelemSecondsUntilSlotEnd: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "seconds_until_slot_end",
+			Help:      "Seconds until the end of the current slot",
+		}),
+		promDishyTelemCurrentSecondsOfSchedule: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "current_seconds_of_schedule",
+			Help:      "Seconds into the current schedule",
+		}),
+		promDishyTelemSnrDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "snr_db",
+			Help:      "Signal to noise ratio in dB",
+		}),
+		promDishyTelemL1SnrAvgDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "l1_snr_avg_db",
+			Help:      "Average L1 signal to noise ratio in dB",
+		}),
+		promDishyTelemL1SnrMinDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "l1_snr_min_db",
+			Help:      "Minimum L1 signal to noise ratio in dB",
+		}),
+		promDishyTelemL1SnrMaxDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "l1_snr_max_db",
+			Help:      "Maximum L1 signal to noise ratio in dB",
+		}),
+		promDishyTelemWbRssiPeakMagDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "wb_rssi_peak_mag_db",
+			Help:      "Wideband RSSI peak magnitude in dB",
+		}),
+		promDishyTelemCeRssiDb: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "ce_rssi_db",
+			Help:      "Channel estimate RSSI in dB",
+		}),
+		promDishyTelemGrantMcs: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "grant_mcs",
+			Help:      "Granted modulation and coding scheme",
+		}),
+		promDishyTelemGrantSymbolsAvg: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "grant_symbols_avg",
+			Help:      "Average granted symbols",
+		}),
+		promDishyTelemDedGrant: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "ded_grant",
+			Help:      "Dedicated grant",
+		}),
+		promDishyTelemEmaVelocity: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "ema_velocity",
+			Help:      "Exponential moving average velocity by axis",
+		}, []string{"axis"}),
+		promDishyTelemProactiveSlotChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "proactive_slot_changes",
+			Help:      "Number of proactive mobility slot changes",
+		}),
+		promDishyTelemReactiveSlotChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "reactive_slot_changes",
+			Help:      "Number of reactive mobility slot changes",
+		}),
+		promDishyTelemSyncFailures: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "sync_failures",
+			Help:      "Number of RF sync failures",
+		}),
+		promDishyTelemOutOfSeq: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "out_of_seq",
+			Help:      "Number of out of sequence frames",
+		}),
+		promDishyTelemUlmapDrops: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "ulmap_drops",
+			Help:      "Number of dropped uplink maps",
+		}),
+		promDishyTelemLabelSwitchFailures: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "label_switch_to_ground_failures",
+			Help:      "Number of failed label switch to ground calls",
+		}),
+		promDishyTelemCellChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "cell_changes",
+			Help:      "Number of observed cell ID changes in transceiver telemetry",
+		}),
+		promDishyTelemSatelliteChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "transceiver",
+			Name:      "satellite_changes",
+			Help:      "Number of observed LMAC satellite ID changes",
+		}),
+		// Dishy Context Metrics
+		promDishyContextInfo: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_info",
+			Help:      "Dishy cell, PoP rack and initial satellite and gateway assignment, always 1",
+		}, []string{"cell_id", "pop_rack_id", "initial_satellite_id", "initial_gateway_id"}),
+		promDishyContextCellID: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_cell_id",
+			Help:      "Current cell ID",
+		}),
+		promDishyContextPopRackID: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_pop_rack_id",
+			Help:      "Current PoP rack ID",
+		}),
+		promDishyContextBackupBeam: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_on_backup_beam",
+			Help:      "Boolean, dishy is on a backup beam",
+		}),
+		promDishyContextSecondsToSlotEnd: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_seconds_to_slot_end",
+			Help:      "Seconds until the current satellite slot ends",
+		}),
+		promDishyContextPingDropRate: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_pop_ping_drop_rate_15s_mean",
+			Help:      "Mean pop ping drop rate over the last 15 seconds",
+		}),
+		promDishyContextPingLatencyMs: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_pop_ping_latency_ms_15s_mean",
+			Help:      "Mean pop ping latency over the last 15 seconds",
+		}),
+		promDishyContextSinceOutage: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_seconds_since_outage",
+			Help:      "Seconds since the last outage of at least duration",
+		}, []string{"duration"}),
+		promDishyContextCellChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_cell_changes",
+			Help:      "Number of observed cell ID changes",
+		}),
+		promDishyContextPopRackChanges: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "context_pop_rack_changes",
+			Help:      "Number of observed PoP rack ID changes",
+		}),
+		// Dishy Boot Metrics
+		promDishyBootsByReason: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "boots_by_reason",
+			Help:      "Lifetime boot count by reason as reported by Dishy",
+		}, []string{"reason"}),
+		promDishyLastBootReason: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "last_boot_reason",
+			Help:      "Reason for the last boot, 1 for the current reason",
+		}, []string{"reason"}),
+		promDishyReboots: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "reboots",
+			Help:      "Number of reboots observed by the exporter by reason",
+		}, []string{"reason"}),
+		// Dishy Location Metrics
+		promDishyLocationLatitude: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "location_latitude_deg",
+			Help:      "Dishy GPS latitude at the configured precision",
+		}),
+		promDishyLocationLongitude: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "location_longitude_deg",
+			Help:      "Dishy GPS longitude at the configured precision",
+		}),
+		promDishyLocationAltitude: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "location_altitude_m",
+			Help:      "Dishy GPS altitude in meters",
+		}),
+		promDishyLocationGeohash: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "dishy",
+			Name:      "location_geohash",
+			Help:      "Geohash prefix of the Dishy location, always 1",
+		}, []string{"geohash"}),
+	}
+}
+
+// Metrics written by router updaters
+type routerMetricSet struct {
+	// Request Metrics
+	promRouterGRPCTime prometheus.Histogram
+	promRouterFailures prometheus.Counter
+	promRouterRequests prometheus.Counter
+	promRouterFailing  prometheus.Gauge
+	// Router Metrics
+	promRouterInfo                  *prometheus.GaugeVec
+	promRouterUptimeS               prometheus.Gauge
+	promRouterCaptivePortal         prometheus.Gauge
+	promRouterPingDropRate          prometheus.Gauge
+	promRouterPingLatencyMs         prometheus.Gauge
+	promRouterChanBusyFraction      *prometheus.GaugeVec
+	promRouterAirtimeFraction       *prometheus.GaugeVec
+	promRouterAlertStatus           *prometheus.GaugeVec
+	promRouterAlerts                prometheus.Gauge
+	promRouterClients               prometheus.Gauge
+	promRouterClientInfo            *prometheus.GaugeVec
+	promRouterClientSignalStrength  *prometheus.GaugeVec
+	promRouterClientSnr             *prometheus.GaugeVec
+	promRouterClientChannelWidth    *prometheus.GaugeVec
+	promRouterClientAssociatedTimeS *prometheus.GaugeVec
+	promRouterClientRxRateMbps      *prometheus.GaugeVec
+	promRouterClientTxRateMbps      *prometheus.GaugeVec
+	promRouterClientRxBytes         *prometheus.CounterVec
+	promRouterClientTxBytes         *prometheus.CounterVec
+	promRouterClientRxErrors        *prometheus.CounterVec
+	// Router Internet Ping Metrics
+	promRouterInternetLatencyMs       *prometheus.GaugeVec
+	promRouterInternetLatencyStddevMs prometheus.Gauge
+	promRouterInternetDropRate        *prometheus.GaugeVec
+	promRouterInternetSinceSuccess    prometheus.Gauge
+	promRouterInternetSinceOutage     *prometheus.GaugeVec
+	promRouterInternetHappyHours      *prometheus.GaugeVec
+	// Router Per-second History Metrics
+	promRouterHistorySamples         prometheus.Counter
+	promRouterHistorySamplesLost     prometheus.Counter
+	promRouterHistoryPingDropSeconds prometheus.Counter
+	promRouterHistoryPingLatencyMs   prometheus.Histogram
+	// Router Client History Metrics
+	promRouterClientHistoryTxMbps     *prometheus.HistogramVec
+	promRouterClientHistoryRxMbps     *prometheus.HistogramVec
+	promRouterClientHistoryRxRateMbps *prometheus.HistogramVec
+	promRouterClientHistoryRssi       *prometheus.HistogramVec
+	promRouterClientLimitedSeconds    *prometheus.CounterVec
+	promRouterClientHistoryLost       *prometheus.CounterVec
+}
+
+// Creates router metrics with a factory, registering them wherever it registers
+func newRouterMetricSet(metrics promauto.Factory) *routerMetricSet {
+	return &routerMetricSet{
+		// Request Metrics
+		promRouterGRPCTime: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "router_grpc_time",
+			Help:      "Time spend interacting with router GRPC",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		promRouterFailures: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "router_failures",
+			Help:      "Number of router request failures",
+		}),
+		promRouterRequests: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "router_requests",
+			Help:      "Number of router requests",
+		}),
+		promRouterFailing: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "exporter",
+			Name:      "router_failing",
+			Help:      "Boolean indicator if requests to the router are failing",
+		}),
+		// Router Metrics
+		promRouterInfo: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "info",
+			Help:      "Router info, always 1",
+		}, []string{"id", "hardware_version", "software_version", "ipv4_wan_address"}),
+		promRouterUptimeS: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "uptime_s",
+			Help:      "Uptime of the router",
+		}),
+		promRouterCaptivePortal: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "captive_portal_enabled",
+			Help:      "Boolean, router captive portal is enabled",
+		}),
+		promRouterPingDropRate: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "ping_drop_rate",
+			Help:      "Current internet ping drop rate measured by the router",
+		}),
+		promRouterPingLatencyMs: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "ping_latency_ms",
+			Help:      "Current internet ping latency measured by the router",
+		}),
+		promRouterChanBusyFraction: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "channel_busy_fraction",
+			Help:      "Fraction of time the channel is busy by band",
+		}, []string{"band"}),
+		promRouterAirtimeFraction: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "airtime_fraction",
+			Help:      "Fraction of airtime by band and use (tx, rx, obss, edcca)",
+		}, []string{"band", "type"}),
+		promRouterAlertStatus: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "alert_status",
+			Help:      "Status of router alerts",
+		}, []string{"alert"}),
+		promRouterAlerts: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "alerts",
+			Help:      "Number of current router alerts",
+		}),
+		promRouterClients: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "clients",
+			Help:      "Number of clients connected to the router",
+		}),
+		promRouterClientInfo: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_info",
+			Help:      "Router client info, always 1",
+		}, []string{"mac", "name", "ip", "iface", "role"}),
+		promRouterClientSignalStrength: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_signal_strength",
+			Help:      "Client signal strength in dBm",
+		}, []string{"mac", "name"}),
+		promRouterClientSnr: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_snr",
+			Help:      "Client signal to noise ratio",
+		}, []string{"mac", "name"}),
+		promRouterClientChannelWidth: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_channel_width",
+			Help:      "Client channel width in MHz",
+		}, []string{"mac", "name"}),
+		promRouterClientAssociatedTimeS: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_associated_time_s",
+			Help:      "Seconds the client has been associated",
+		}, []string{"mac", "name"}),
+		promRouterClientRxRateMbps: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_rx_rate_mbps",
+			Help:      "Client receive PHY rate in Mbps",
+		}, []string{"mac", "name"}),
+		promRouterClientTxRateMbps: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_tx_rate_mbps",
+			Help:      "Client transmit PHY rate in Mbps",
+		}, []string{"mac", "name"}),
+		promRouterClientRxBytes: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_rx_bytes",
+			Help:      "Bytes received from the client",
+		}, []string{"mac", "name"}),
+		promRouterClientTxBytes: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_tx_bytes",
+			Help:      "Bytes transmitted to the client",
+		}, []string{"mac", "name"}),
+		promRouterClientRxErrors: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_rx_errors",
+			Help:      "Receive errors from the client",
+		}, []string{"mac", "name"}),
+		// Router Internet Ping Metrics
+		promRouterInternetLatencyMs: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_latency_mean_ms",
+			Help:      "Mean internet ping latency measured by the router by averaging window",
+		}, []string{"window"}),
+		promRouterInternetLatencyStddevMs: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_latency_stddev_ms",
+			Help:      "Standard deviation of internet ping latency measured by the router",
+		}),
+		promRouterInternetDropRate: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_drop_rate",
+			Help:      "Internet ping drop rate measured by the router by averaging window",
+		}, []string{"window"}),
+		promRouterInternetSinceSuccess: metrics.NewGauge(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_seconds_since_last_success",
+			Help:      "Seconds since the last successful internet ping",
+		}),
+		promRouterInternetSinceOutage: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_seconds_since_last_outage",
+			Help:      "Seconds since the last internet outage of at least the given duration",
+		}, []string{"duration"}),
+		promRouterInternetHappyHours: metrics.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "internet_happy_hours",
+			Help:      "Hours of the last day without an internet outage of at least the given duration",
+		}, []string{"duration"}),
+		// Router Per-second History Metrics
+		promRouterHistorySamples: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "history_samples",
+			Help:      "Number of per-second router history samples ingested",
+		}),
+		promRouterHistorySamplesLost: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "history_samples_lost",
+			Help:      "Number of per-second router history samples overwritten between polls",
+		}),
+		promRouterHistoryPingDropSeconds: metrics.NewCounter(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "history_ping_drop_seconds",
+			Help:      "Sum of per-second router ping drop rate, seconds of full loss",
+		}),
+		promRouterHistoryPingLatencyMs: metrics.NewHistogram(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "history_ping_latency_ms",
+			Help:      "Histogram of per-second router ping latency",
+			Buckets:   prometheus.ExponentialBucketsRange(10, 2000, 15),
+		}),
+		// Router Client History Metrics
+		promRouterClientHistoryTxMbps: metrics.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_history_tx_throughput_mbps",
+			Help:      "Histogram of per-second throughput to the client",
+			Buckets:   prometheus.ExponentialBucketsRange(0.01, 1000, 11),
+		}, []string{"mac"}),
+		promRouterClientHistoryRxMbps: metrics.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_history_rx_throughput_mbps",
+			Help:      "Histogram of per-second throughput from the client",
+			Buckets:   prometheus.ExponentialBucketsRange(0.01, 1000, 11),
+		}, []string{"mac"}),
+		promRouterClientHistoryRxRateMbps: metrics.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_history_rx_rate_mbps",
+			Help:      "Histogram of per-second client receive PHY rate",
+			Buckets:   prometheus.ExponentialBucketsRange(1, 2400, 12),
+		}, []string{"mac"}),
+		promRouterClientHistoryRssi: metrics.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_history_rssi",
+			Help:      "Histogram of per-second client RSSI in dBm",
+			Buckets:   prometheus.LinearBuckets(-90, 5, 13),
+		}, []string{"mac"}),
+		promRouterClientLimitedSeconds: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_throughput_limited_seconds",
+			Help:      "Seconds of client history by throughput limiting reason",
+		}, []string{"mac", "reason"}),
+		promRouterClientHistoryLost: metrics.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "starlink",
+			Subsystem: "router",
+			Name:      "client_history_samples_lost",
+			Help:      "Number of per-second client history samples overwritten between polls",
+		}, []string{"mac"}),
+	}
+}
+
+// Registers Dishy metrics directly, or behind a scrapeCollector in scrape mode
+func registerMetrics(cacheTTL time.Duration) {
+	if mode == modeScrape {
+		prom.MustRegister(newScrapeCollector(dishyCollectors, UpdateMetrics, cacheTTL))
+	} else {
+		prom.MustRegister(dishyCollectors...)
+	}
+}
+
 func promInit() {
 	// Serve endpoint
 	http.Handle("/metrics", promhttp.HandlerFor(prom, promhttp.HandlerOpts{}))
+	http.HandleFunc("/probe", serveProbe)
+	http.HandleFunc("/dishy/reboots", serveReboots)
 	log.WithField("Listen", promAddr).Info("Prometheus Starting")
 	if err := http.ListenAndServe(promAddr, nil); err != nil {
 		log.WithField("Error", err).Fatal("Failed to start Prometheus")
@@ -0,0 +1,559 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Remote write settings
+var (
+	remoteWriteURL     string                 // Prometheus remote_write endpoint, disabled if empty
+	remoteWriteLabels  string                 // Extra labels added to every series (k=v,k=v)
+	remoteWriteBatch   int           = 1000   // Samples per request
+	remoteWriteBuffer  int           = 100000 // Maximum pending samples, oldest dropped beyond this
+	remoteWriteTimeout string        = "10s"  // Timeout for each request
+	remoteWriteWAL     string                 // File holding pending samples across restarts
+	remoteWriteBackoff = time.Minute          // Upper bound on retry delay
+)
+
+func init() {
+	flag.StringVar(&remoteWriteURL, "remoteWriteURL", remoteWriteURL, "Prometheus remote_write URL for per-second history samples (disabled if empty)")
+	flag.StringVar(&remoteWriteLabels, "remoteWriteLabels", remoteWriteLabels, "Extra labels for remote written series (e.g. site=home,dish=roof)")
+	flag.IntVar(&remoteWriteBatch, "remoteWriteBatch", remoteWriteBatch, "Maximum samples per remote_write request")
+	flag.IntVar(&remoteWriteBuffer, "remoteWriteBuffer", remoteWriteBuffer, "Maximum samples held while remote_write is failing")
+	flag.StringVar(&remoteWriteTimeout, "remoteWriteTimeout", remoteWriteTimeout, "Timeout for each remote_write request (go time.Duration)")
+	flag.StringVar(&remoteWriteWAL, "remoteWriteWAL", remoteWriteWAL, "File used to persist pending remote_write samples across restarts")
+}
+
+// Remote write sender, nil when disabled
+var remoteWriter *remoteWrite
+
+// A single timestamped sample for one series
+type rwSample struct {
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp int64             `json:"ts"` // Milliseconds since epoch
+	Value     float64           `json:"value"`
+}
+
+// WAL record removing N pending samples starting at index At, appended after
+// samples are sent or dropped so a restart doesn't send them again
+type walDrop struct {
+	N  int `json:"drop"`
+	At int `json:"drop_at,omitempty"`
+}
+
+// WAL record of the last history sample enqueued, so a restart skips the
+// samples of the dish's history buffer that were already queued
+type walMark struct {
+	Seq uint64 `json:"last_seq"`
+	TS  int64  `json:"last_ts"` // Milliseconds since epoch
+}
+
+// A WAL line, either a sample, a drop or a mark
+type walRecord struct {
+	rwSample
+	walDrop
+	walMark
+}
+
+// How far the timestamp derived for the last queued sample may move between
+// polls before the dish is assumed to have restarted its history counter
+const historyCounterSlack = 30 * time.Second
+
+// Buffers samples and pushes them to a Prometheus remote_write endpoint,
+// retrying with backoff while the endpoint is unavailable
+type remoteWrite struct {
+	url      string
+	labels   map[string]string
+	batch    int
+	buffer   int
+	wal      string
+	http     *http.Client
+	mu       sync.Mutex
+	pending  []rwSample
+	inflight int     // Samples at the front of pending currently being sent
+	walLines int     // Records in the WAL file, more than pending once samples are sent or dropped
+	last     walMark // Last history sample enqueued
+	notify   chan struct{}
+}
+
+// Builds a remote writer from flags, returns nil if remote write is disabled
+func newRemoteWrite() (*remoteWrite, error) {
+	if remoteWriteURL == "" {
+		return nil, nil
+	}
+	if remoteWriteBatch < 1 || remoteWriteBuffer < remoteWriteBatch {
+		return nil, fmt.Errorf("-remoteWriteBuffer %d must be at least -remoteWriteBatch %d, which must be positive",
+			remoteWriteBuffer, remoteWriteBatch)
+	}
+	reqTimeout, err := parseDurationFlag("remoteWriteTimeout", remoteWriteTimeout)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := parseLabelFlag("remoteWriteLabels", remoteWriteLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	rw := &remoteWrite{
+		url:    remoteWriteURL,
+		labels: labels,
+		batch:  remoteWriteBatch,
+		buffer: remoteWriteBuffer,
+		wal:    remoteWriteWAL,
+		http:   &http.Client{Timeout: reqTimeout},
+		notify: make(chan struct{}, 1),
+	}
+
+	// Pick up anything left over from the last run
+	if err := rw.loadWAL(); err != nil {
+		return nil, err
+	}
+
+	return rw, nil
+}
+
+// Queues per-second Dishy history samples, timestamps derived from their
+// distance to the newest sample which was taken at about polled. Samples at
+// or before the last one queued are skipped, so a restart doesn't queue the
+// whole buffer again.
+func (rw *remoteWrite) enqueueHistory(seqs []uint64, current uint64, polled time.Time, drops, latencies, downlink, uplink []float32) {
+	if len(seqs) == 0 {
+		return
+	}
+
+	// Identify the dish when known
+	labels := make(map[string]string, len(rw.labels)+1)
+	for k, v := range rw.labels {
+		labels[k] = v
+	}
+	dishy.labelsLock.Lock()
+	if id := dishy.labels["id"]; id != "" {
+		labels["id"] = id
+	}
+	dishy.labelsLock.Unlock()
+
+	rw.mu.Lock()
+	last := rw.last
+	rw.mu.Unlock()
+
+	// Sequence numbers are only comparable while the dish keeps counting
+	// from the same start
+	sameCounter := last.TS > 0 && current > last.Seq &&
+		absDuration(polled.Add(-time.Duration(current-1-last.Seq)*time.Second).Sub(time.UnixMilli(last.TS))) < historyCounterSlack
+
+	samples := make([]rwSample, 0, len(seqs)*4)
+	for _, seq := range seqs {
+		ts := polled.Add(-time.Duration(current-1-seq) * time.Second).UnixMilli()
+		if (sameCounter && seq <= last.Seq) || (!sameCounter && ts <= last.TS) {
+			continue
+		}
+		// Poll time jitter must not move a series backwards
+		if ts <= last.TS {
+			ts = last.TS + 1
+		}
+		last = walMark{Seq: seq, TS: ts}
+
+		drop := historyValue(drops, seq)
+		samples = append(samples,
+			rwSample{Name: "starlink_dishy_sample_pop_ping_drop_rate", Labels: labels, Timestamp: ts, Value: drop},
+			rwSample{Name: "starlink_dishy_sample_downlink_throughput_bps", Labels: labels, Timestamp: ts, Value: historyValue(downlink, seq)},
+			rwSample{Name: "starlink_dishy_sample_uplink_throughput_bps", Labels: labels, Timestamp: ts, Value: historyValue(uplink, seq)},
+		)
+		if drop < 1 {
+			samples = append(samples,
+				rwSample{Name: "starlink_dishy_sample_pop_ping_latency_ms", Labels: labels, Timestamp: ts, Value: historyValue(latencies, seq)})
+		}
+	}
+	if len(samples) > 0 {
+		rw.enqueue(samples, last)
+	}
+}
+
+// Returns the absolute value of d
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// Adds samples to the pending buffer, appends them and the mark of the last
+// history sample if set to the WAL and wakes the sender
+func (rw *remoteWrite) enqueue(samples []rwSample, last walMark) {
+	rw.mu.Lock()
+	rw.pending = append(rw.pending, samples...)
+	if last.TS > 0 {
+		rw.last = last
+	}
+	var dropped walDrop
+	if over := len(rw.pending) - rw.buffer; over > 0 {
+		// Oldest samples not already being sent
+		log.WithField("Dropped", over).Warn("Remote write buffer full, dropping oldest samples")
+		promRemoteWriteDropped.Add(float64(over))
+		dropped = walDrop{N: over, At: rw.inflight}
+		rw.pending = dropped.apply(rw.pending)
+	}
+	promRemoteWritePending.Set(float64(len(rw.pending)))
+	err := rw.appendWAL(samples, last, dropped)
+	if err == nil {
+		err = rw.compactWAL()
+	}
+	rw.mu.Unlock()
+
+	if err != nil {
+		log.WithField("Error", err).Error("Failed to write remote write WAL")
+	}
+
+	select {
+	case rw.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Sends pending samples until ctx is done
+func (rw *remoteWrite) run(ctx context.Context) {
+	log.WithField("URL", rw.url).Info("Remote write enabled")
+	delay := time.Second
+	for {
+		// Wait for samples, or retry after a failure
+		select {
+		case <-ctx.Done():
+			return
+		case <-rw.notify:
+		}
+
+		for {
+			rw.mu.Lock()
+			n := len(rw.pending)
+			if n > rw.batch {
+				n = rw.batch
+			}
+			batch := rw.pending[:n:n]
+			rw.inflight = n
+			rw.mu.Unlock()
+			if n == 0 {
+				break
+			}
+
+			retry, err := rw.send(ctx, batch)
+			if err != nil && retry {
+				log.WithFields(logrus.Fields{"Error": err, "Retry": delay}).Warn("Remote write failed")
+				promRemoteWriteFailures.Inc()
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(delay):
+				}
+				if delay *= 2; delay > remoteWriteBackoff {
+					delay = remoteWriteBackoff
+				}
+				continue
+			}
+			delay = time.Second
+
+			if err != nil {
+				log.WithField("Error", err).Error("Remote write rejected samples, dropping batch")
+				promRemoteWriteFailures.Inc()
+				promRemoteWriteDropped.Add(float64(n))
+			} else {
+				promRemoteWriteSent.Add(float64(n))
+			}
+			rw.drop(n)
+		}
+	}
+}
+
+// Removes n sent samples from the front of the pending buffer
+func (rw *remoteWrite) drop(n int) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.pending = rw.pending[n:]
+	rw.inflight = 0
+	promRemoteWritePending.Set(float64(len(rw.pending)))
+	err := rw.appendWAL(nil, walMark{}, walDrop{N: n})
+	if err == nil {
+		err = rw.compactWAL()
+	}
+	if err != nil {
+		log.WithField("Error", err).Error("Failed to write remote write WAL")
+	}
+}
+
+// Sends one batch, reporting whether a failure is worth retrying
+func (rw *remoteWrite) send(ctx context.Context, batch []rwSample) (retry bool, err error) {
+	body := snappyEncode(encodeWriteRequest(batch))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rw.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	req.Header.Set("User-Agent", "starlink-exporter")
+
+	resp, err := rw.http.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+
+	switch {
+	case resp.StatusCode/100 == 2:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5:
+		return true, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	default:
+		return false, fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+}
+
+// Replays the WAL file if configured into pending samples
+func (rw *remoteWrite) loadWAL() error {
+	if rw.wal == "" {
+		return nil
+	}
+	f, err := os.Open(rw.wal)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read remote write WAL: %w", err)
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		var rec walRecord
+		if err := dec.Decode(&rec); err == io.EOF {
+			break
+		} else if err != nil {
+			// Most likely a record cut short by a crash, keep what was read
+			log.WithFields(logrus.Fields{"WAL": rw.wal, "Error": err}).Warn("Ignoring unreadable end of remote write WAL")
+			break
+		}
+		rw.walLines++
+		switch {
+		case rec.N > 0:
+			rw.pending = rec.walDrop.apply(rw.pending)
+		case rec.Name == "":
+			rw.last = rec.walMark
+		default:
+			rw.pending = append(rw.pending, rec.rwSample)
+		}
+	}
+	if over := len(rw.pending) - rw.buffer; over > 0 {
+		rw.pending = rw.pending[over:]
+	}
+	if err := rw.compactWAL(); err != nil {
+		return fmt.Errorf("unable to rewrite remote write WAL %s: %w", rw.wal, err)
+	}
+
+	if len(rw.pending) > 0 {
+		log.WithField("Samples", len(rw.pending)).Info("Loaded pending remote write samples")
+		rw.notify <- struct{}{}
+	}
+	return nil
+}
+
+// Appends samples, a mark and a drop if any to the WAL file if configured,
+// callers must hold mu
+func (rw *remoteWrite) appendWAL(samples []rwSample, last walMark, dropped walDrop) error {
+	if rw.wal == "" {
+		return nil
+	}
+	f, err := os.OpenFile(rw.wal, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(f)
+	err = writeSamples(buf, samples)
+	if err == nil && last.TS > 0 {
+		err = json.NewEncoder(buf).Encode(last)
+		rw.walLines++
+	}
+	if err == nil && dropped.N > 0 {
+		err = json.NewEncoder(buf).Encode(dropped)
+		rw.walLines++
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	rw.walLines += len(samples)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Removes the dropped samples from pending
+func (d walDrop) apply(pending []rwSample) []rwSample {
+	if d.At >= len(pending) {
+		return pending
+	} else if d.At+d.N > len(pending) {
+		return pending[:d.At]
+	}
+	return append(pending[:d.At], pending[d.At+d.N:]...)
+}
+
+// Rewrites the WAL file with only the last mark and pending samples once it
+// holds more than twice as many records, keeping the cost per sample
+// constant. Callers must hold mu
+func (rw *remoteWrite) compactWAL() error {
+	if rw.wal == "" || rw.walLines <= 2*(len(rw.pending)+1) {
+		return nil
+	}
+
+	tmp := rw.wal + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	buf := bufio.NewWriter(f)
+	lines := len(rw.pending)
+	if rw.last.TS > 0 {
+		err = json.NewEncoder(buf).Encode(rw.last)
+		lines++
+	}
+	if err == nil {
+		err = writeSamples(buf, rw.pending)
+	}
+	if err == nil {
+		err = buf.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, rw.wal); err != nil {
+		return err
+	}
+	rw.walLines = lines
+	return nil
+}
+
+// Writes samples to w as JSON, one per line
+func writeSamples(w io.Writer, samples []rwSample) error {
+	enc := json.NewEncoder(w)
+	for _, s := range samples {
+		if err := enc.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Encodes samples as a prometheus.WriteRequest protobuf, grouped by series
+func encodeWriteRequest(samples []rwSample) []byte {
+	type series struct {
+		labels  [][2]string
+		samples []rwSample
+	}
+	var order []string
+	bySeries := make(map[string]*series)
+	for _, s := range samples {
+		labels := [][2]string{{"__name__", s.Name}}
+		for k, v := range s.Labels {
+			labels = append(labels, [2]string{k, v})
+		}
+		sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+
+		var key strings.Builder
+		for _, l := range labels {
+			key.WriteString(l[0] + "\xff" + l[1] + "\xff")
+		}
+		ts, ok := bySeries[key.String()]
+		if !ok {
+			ts = &series{labels: labels}
+			bySeries[key.String()] = ts
+			order = append(order, key.String())
+		}
+		ts.samples = append(ts.samples, s)
+	}
+
+	var req []byte
+	for _, key := range order {
+		ts := bySeries[key]
+		var msg []byte
+		for _, l := range ts.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l[0])
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l[1])
+			msg = protowire.AppendTag(msg, 1, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, label)
+		}
+		for _, s := range ts.samples {
+			var sample []byte
+			sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+			sample = protowire.AppendFixed64(sample, math.Float64bits(s.Value))
+			sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+			sample = protowire.AppendVarint(sample, uint64(s.Timestamp))
+			msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+			msg = protowire.AppendBytes(msg, sample)
+		}
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, msg)
+	}
+	return req
+}
+
+// Encodes src in the snappy block format using only literals, which every
+// snappy decoder accepts, avoiding a compression dependency
+func snappyEncode(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(src)+len(src)/60*3+3)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+	for len(src) > 0 {
+		n := len(src)
+		if n > 65536 {
+			n = 65536
+		}
+		switch {
+		case n <= 60:
+			dst = append(dst, byte(n-1)<<2)
+		case n <= 256:
+			dst = append(dst, 60<<2, byte(n-1))
+		default:
+			dst = append(dst, 61<<2, byte(n-1), byte((n-1)>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
+
+// Parses a k=v,k=v label flag
+func parseLabelFlag(name string, value string) (map[string]string, error) {
+	labels := make(map[string]string)
+	if value == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("invalid -%s %q: expected name=value", name, pair)
+		}
+		labels[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return labels, nil
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type decodedSeries struct {
+	labels  map[string]string
+	samples [][2]float64 // Timestamp, value
+}
+
+// Decodes a prometheus.WriteRequest using only the fields encodeWriteRequest writes
+func decodeWriteRequest(t *testing.T, b []byte) []decodedSeries {
+	t.Helper()
+	var out []decodedSeries
+	for len(b) > 0 {
+		msg := consumeBytes(t, &b, 1)
+		ts := decodedSeries{labels: make(map[string]string)}
+		for len(msg) > 0 {
+			num, typ, n := protowire.ConsumeTag(msg)
+			if n < 0 || typ != protowire.BytesType {
+				t.Fatalf("bad timeseries tag %d type %d", num, typ)
+			}
+			msg = msg[n:]
+			field, n := protowire.ConsumeBytes(msg)
+			if n < 0 {
+				t.Fatal("bad timeseries field")
+			}
+			msg = msg[n:]
+			switch num {
+			case 1:
+				name := consumeBytes(t, &field, 1)
+				value := consumeBytes(t, &field, 2)
+				ts.labels[string(name)] = string(value)
+			case 2:
+				var sample [2]float64
+				for len(field) > 0 {
+					num, typ, n := protowire.ConsumeTag(field)
+					field = field[n:]
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						v, n := protowire.ConsumeFixed64(field)
+						sample[1] = math.Float64frombits(v)
+						field = field[n:]
+					case num == 2 && typ == protowire.VarintType:
+						v, n := protowire.ConsumeVarint(field)
+						sample[0] = float64(int64(v))
+						field = field[n:]
+					default:
+						t.Fatalf("bad sample tag %d type %d", num, typ)
+					}
+				}
+				ts.samples = append(ts.samples, sample)
+			default:
+				t.Fatalf("unexpected timeseries field %d", num)
+			}
+		}
+		out = append(out, ts)
+	}
+	return out
+}
+
+// Consumes a length delimited field, failing unless it has the given number
+func consumeBytes(t *testing.T, b *[]byte, want protowire.Number) []byte {
+	t.Helper()
+	num, typ, n := protowire.ConsumeTag(*b)
+	if n < 0 || num != want || typ != protowire.BytesType {
+		t.Fatalf("expected bytes field %d, got %d type %d", want, num, typ)
+	}
+	*b = (*b)[n:]
+	v, n := protowire.ConsumeBytes(*b)
+	if n < 0 {
+		t.Fatalf("truncated field %d", want)
+	}
+	*b = (*b)[n:]
+	return v
+}
+
+func TestEncodeWriteRequest(t *testing.T) {
+	labels := map[string]string{"id": "ut01", "site": "roof"}
+	samples := []rwSample{
+		{Name: "starlink_dishy_sample_pop_ping_drop_rate", Labels: labels, Timestamp: 1000, Value: 0},
+		{Name: "starlink_dishy_sample_pop_ping_latency_ms", Labels: labels, Timestamp: 1000, Value: 31.5},
+		{Name: "starlink_dishy_sample_pop_ping_drop_rate", Labels: labels, Timestamp: 2000, Value: 0.25},
+	}
+
+	got := decodeWriteRequest(t, encodeWriteRequest(samples))
+	if len(got) != 2 {
+		t.Fatalf("expected 2 series, got %d", len(got))
+	}
+
+	drops := got[0]
+	if drops.labels["__name__"] != "starlink_dishy_sample_pop_ping_drop_rate" || drops.labels["id"] != "ut01" || drops.labels["site"] != "roof" {
+		t.Errorf("unexpected labels %v", drops.labels)
+	}
+	if want := [][2]float64{{1000, 0}, {2000, 0.25}}; len(drops.samples) != 2 || drops.samples[0] != want[0] || drops.samples[1] != want[1] {
+		t.Errorf("expected samples %v, got %v", want, drops.samples)
+	}
+
+	latency := got[1]
+	if latency.labels["__name__"] != "starlink_dishy_sample_pop_ping_latency_ms" {
+		t.Errorf("unexpected labels %v", latency.labels)
+	}
+	if len(latency.samples) != 1 || latency.samples[0] != [2]float64{1000, 31.5} {
+		t.Errorf("unexpected samples %v", latency.samples)
+	}
+}
+
+// Decodes a snappy block made only of literals, as snappyEncode writes
+func snappyDecodeLiterals(t *testing.T, b []byte) []byte {
+	t.Helper()
+	length, n := binary.Uvarint(b)
+	if n <= 0 {
+		t.Fatal("bad snappy length header")
+	}
+	b = b[n:]
+	var out []byte
+	for len(b) > 0 {
+		tag := b[0]
+		if tag&3 != 0 {
+			t.Fatalf("expected literal tag, got %#x", tag)
+		}
+		size := int(tag >> 2)
+		b = b[1:]
+		switch size {
+		case 60:
+			size, b = int(b[0]), b[1:]
+		case 61:
+			size, b = int(b[0])|int(b[1])<<8, b[2:]
+		}
+		size++
+		if size > len(b) {
+			t.Fatalf("literal of %d bytes overruns %d remaining", size, len(b))
+		}
+		out = append(out, b[:size]...)
+		b = b[size:]
+	}
+	if uint64(len(out)) != length {
+		t.Fatalf("header says %d bytes, decoded %d", length, len(out))
+	}
+	return out
+}
+
+func TestSnappyEncode(t *testing.T) {
+	for _, size := range []int{0, 1, 60, 61, 256, 257, 65536, 65537, 200000} {
+		src := make([]byte, size)
+		for i := range src {
+			src[i] = byte(i * 7)
+		}
+		if got := snappyDecodeLiterals(t, snappyEncode(src)); !bytes.Equal(got, src) {
+			t.Errorf("size %d did not round trip", size)
+		}
+	}
+}
+
+func TestRemoteWriteWAL(t *testing.T) {
+	wal := filepath.Join(t.TempDir(), "wal")
+	newRW := func() *remoteWrite {
+		rw := &remoteWrite{batch: 2, buffer: 10, wal: wal, notify: make(chan struct{}, 1)}
+		if err := rw.loadWAL(); err != nil {
+			t.Fatal(err)
+		}
+		return rw
+	}
+	sample := func(ts int64) rwSample {
+		return rwSample{Name: "starlink_dishy_sample_pop_ping_drop_rate", Timestamp: ts}
+	}
+
+	rw := newRW()
+	rw.enqueue([]rwSample{sample(1), sample(2), sample(3)}, walMark{})
+	rw.enqueue([]rwSample{sample(4)}, walMark{})
+	rw.drop(2)
+
+	// Only unsent samples come back after a restart
+	rw = newRW()
+	if len(rw.pending) != 2 || rw.pending[0].Timestamp != 3 || rw.pending[1].Timestamp != 4 {
+		t.Fatalf("expected samples 3 and 4 pending, got %v", rw.pending)
+	}
+
+	// Overflow drops the oldest samples not being sent
+	rw.inflight = 1
+	for ts := int64(5); ts <= 13; ts++ {
+		rw.enqueue([]rwSample{sample(ts)}, walMark{})
+	}
+	rw = newRW()
+	if len(rw.pending) != 10 || rw.pending[0].Timestamp != 3 || rw.pending[1].Timestamp != 5 || rw.pending[9].Timestamp != 13 {
+		t.Fatalf("expected samples 3 and 5-13 pending, got %v", rw.pending)
+	}
+
+	rw.drop(10)
+	if rw = newRW(); len(rw.pending) != 0 {
+		t.Fatalf("expected nothing pending, got %v", rw.pending)
+	}
+}
+
+func TestRemoteWriteHistoryRestart(t *testing.T) {
+	dishy = &dishDevice{}
+	wal := filepath.Join(t.TempDir(), "wal")
+	newRW := func() *remoteWrite {
+		rw := &remoteWrite{batch: 100, buffer: 1000, wal: wal, notify: make(chan struct{}, 1)}
+		if err := rw.loadWAL(); err != nil {
+			t.Fatal(err)
+		}
+		return rw
+	}
+	seqs := func(from, to uint64) (s []uint64) {
+		for seq := from; seq < to; seq++ {
+			s = append(s, seq)
+		}
+		return s
+	}
+	polled := time.UnixMilli(1_700_000_000_000)
+
+	rw := newRW()
+	rw.enqueueHistory(seqs(0, 10), 10, polled, nil, nil, nil, nil)
+	rw.drop(len(rw.pending))
+
+	// After a restart the whole buffer comes back, with the poll landing 1.1s
+	// early so the first new sample would be stamped before the last one sent
+	rw = newRW()
+	rw.enqueueHistory(seqs(0, 15), 15, polled.Add(3900*time.Millisecond), nil, nil, nil, nil)
+	if len(rw.pending) != 5*4 {
+		t.Fatalf("expected 5 new seconds of samples, got %d samples", len(rw.pending))
+	}
+	last := polled.UnixMilli()
+	for i := 0; i < len(rw.pending); i += 4 {
+		if ts := rw.pending[i].Timestamp; ts <= last {
+			t.Fatalf("timestamp %d not after %d", ts, last)
+		} else {
+			last = ts
+		}
+	}
+
+	// A dish that restarted its counter is compared by timestamp
+	rw.drop(len(rw.pending))
+	rw = newRW()
+	rw.enqueueHistory(seqs(0, 3), 3, polled.Add(time.Minute), nil, nil, nil, nil)
+	if len(rw.pending) != 3*4 {
+		t.Fatalf("expected 3 seconds of samples after a dish restart, got %d samples", len(rw.pending))
+	}
+}
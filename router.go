@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"flag"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"google.golang.org/grpc"
+)
+
+// Router GRPC endpoint, disabled if empty
+var routerHost string
+
+func init() {
+	flag.StringVar(&routerHost, "routerHost", routerHost, "IP and port of the Starlink router GRPC endpoint, e.g. 192.168.1.1:9000 (disabled if empty)")
+}
+
+// List of router alerts by field name
+var routerAlerts = []string{
+	"ThermalThrottle",
+	"InstallPending",
+	"FreshlyFused",
+	"LanEthSlowLink_10",
+	"LanEthSlowLink_100",
+	"Inconsistent_2GhzAntennaePerformance_15Db",
+	"Inconsistent_5GhzAntennaePerformance_15Db",
+	"Poor_2GhzAntennaePerformance_80Db",
+	"Poor_5GhzAntennaePerformance_80Db",
+}
+
+// A router, the metrics its updaters write and the state they carry between polls
+type routerDevice struct {
+	*routerMetricSet
+	client *deviceClient
+
+	infoLabels      []string
+	history         historyCursor
+	clientsSeen     map[string]routerClientSeries // Clients exported in the last poll by MAC
+	clientHistories map[string]*historyCursor     // Per-client history positions by MAC
+}
+
+func newRouterDevice(conn grpc.ClientConnInterface, m *routerMetricSet) *routerDevice {
+	return &routerDevice{
+		routerMetricSet: m,
+		client:          newRouterClient(conn, m),
+		clientsSeen:     make(map[string]routerClientSeries),
+		clientHistories: make(map[string]*historyCursor),
+	}
+}
+
+// Returns the router's updaters by name
+func (r *routerDevice) updaters() map[string]func(context.Context) {
+	return map[string]func(context.Context){
+		"Router":        r.updateRouterMetrics,
+		"RouterClients": r.updateRouterClientMetrics,
+		"RouterPing":    r.updateRouterPingMetrics,
+		"RouterHistory": r.updateRouterHistoryMetrics,
+		"ClientHistory": r.updateClientHistoryMetrics,
+	}
+}
+
+// Connects to the router if configured, retrying in the background
+func routerInit() error {
+	if routerHost == "" {
+		return nil
+	}
+
+	dialConf, err := newDialConfig(routerHost)
+	if err != nil {
+		return err
+	}
+	conn, err := dialConf.dial()
+	if err != nil {
+		return err
+	}
+	routerDev = newRouterDevice(conn, routerMetrics)
+	router = routerDev.client
+
+	go watchConnState(conn, "Router", promRouterConnState, nil)
+
+	return nil
+}
+
+// Requests router status and updates router metrics
+func (r *routerDevice) updateRouterMetrics(ctx context.Context) {
+	status, err := r.client.GetWifiStatus(ctx)
+	if err != nil {
+		return
+	}
+
+	// Router Info
+	info := status.GetDeviceInfo()
+	setInfoLabels(r.promRouterInfo, &r.infoLabels,
+		info.GetId(),
+		info.GetHardwareVersion(),
+		info.GetSoftwareVersion(),
+		status.GetIpv4WanAddress(),
+	)
+	r.promRouterUptimeS.Set(float64(status.GetDeviceState().GetUptimeS()))
+
+	var captivePortal float64
+	if status.GetCaptivePortalEnabled() {
+		captivePortal = 1
+	}
+	r.promRouterCaptivePortal.Set(captivePortal)
+
+	// Internet Ping
+	r.promRouterPingDropRate.Set(float64(status.GetPingDropRate()))
+	r.promRouterPingLatencyMs.Set(float64(status.GetPingLatencyMs()))
+
+	// Per-band Airtime
+	r.updateRouterBand("2.4GHz", status.GetRf_2GhzStatus())
+	r.updateRouterBand("5GHz", status.GetRf_5GhzStatus())
+
+	// Router Alert Booleans
+	var firing float64
+	for _, name := range routerAlerts {
+		alerting := isAlerting(status.GetAlerts(), name)
+		r.promRouterAlertStatus.WithLabelValues(name).Set(alerting)
+		firing += alerting
+	}
+	r.promRouterAlerts.Set(firing)
+}
+
+// Exports airtime fractions for one band
+func (r *routerDevice) updateRouterBand(band string, status *starlink.WifiBandStatus) {
+	if status == nil {
+		return
+	}
+	r.promRouterChanBusyFraction.WithLabelValues(band).Set(float64(status.GetChanBusyTimeFraction()))
+	r.promRouterAirtimeFraction.WithLabelValues(band, "tx").Set(float64(status.GetTxAirTimeFraction()))
+	r.promRouterAirtimeFraction.WithLabelValues(band, "rx").Set(float64(status.GetRxAirTimeFraction()))
+	r.promRouterAirtimeFraction.WithLabelValues(band, "obss").Set(float64(status.GetObssAirTimeFraction()))
+	r.promRouterAirtimeFraction.WithLabelValues(band, "edcca").Set(float64(status.GetEdccaAirTimeFraction()))
+}
+
+// Requests router internet ping metrics and updates router ping metrics
+func (r *routerDevice) updateRouterPingMetrics(ctx context.Context) {
+	resp, err := r.client.GetWifiPingMetrics(ctx)
+	if err != nil {
+		return
+	}
+	ping := resp.GetInternet()
+
+	// Rolling Averages
+	r.promRouterInternetLatencyMs.WithLabelValues("current").Set(float64(ping.GetLatencyMeanMs()))
+	r.promRouterInternetLatencyMs.WithLabelValues("5m").Set(float64(ping.GetLatencyMeanMs_5M()))
+	r.promRouterInternetLatencyMs.WithLabelValues("1h").Set(float64(ping.GetLatencyMeanMs_1H()))
+	r.promRouterInternetLatencyMs.WithLabelValues("1d").Set(float64(ping.GetLatencyMeanMs_1D()))
+	r.promRouterInternetDropRate.WithLabelValues("current").Set(float64(ping.GetDropRate()))
+	r.promRouterInternetDropRate.WithLabelValues("5m").Set(float64(ping.GetDropRate_5M()))
+	r.promRouterInternetDropRate.WithLabelValues("1h").Set(float64(ping.GetDropRate_1H()))
+	r.promRouterInternetDropRate.WithLabelValues("1d").Set(float64(ping.GetDropRate_1D()))
+	r.promRouterInternetLatencyStddevMs.Set(float64(ping.GetLatencyStddevMs()))
+
+	// Time Since Outages
+	r.promRouterInternetSinceSuccess.Set(float64(ping.GetSecondsSinceLastSuccess()))
+	r.promRouterInternetSinceOutage.WithLabelValues("1s").Set(float64(ping.GetSecondsSinceLast_1SOutage()))
+	r.promRouterInternetSinceOutage.WithLabelValues("2s").Set(float64(ping.GetSecondsSinceLast_2SOutage()))
+	r.promRouterInternetSinceOutage.WithLabelValues("5s").Set(float64(ping.GetSecondsSinceLast_5SOutage()))
+	r.promRouterInternetSinceOutage.WithLabelValues("15s").Set(float64(ping.GetSecondsSinceLast_15SOutage()))
+	r.promRouterInternetSinceOutage.WithLabelValues("60s").Set(float64(ping.GetSecondsSinceLast_60SOutage()))
+	r.promRouterInternetSinceOutage.WithLabelValues("300s").Set(float64(ping.GetSecondsSinceLast_300SOutage()))
+
+	// Happy Hours
+	r.promRouterInternetHappyHours.WithLabelValues("1s").Set(float64(ping.GetHappyHours_1S_1D()))
+	r.promRouterInternetHappyHours.WithLabelValues("2s").Set(float64(ping.GetHappyHours_2S_1D()))
+	r.promRouterInternetHappyHours.WithLabelValues("5s").Set(float64(ping.GetHappyHours_5S_1D()))
+}
+
+// Requests router history and ingests samples new since the last poll
+func (r *routerDevice) updateRouterHistoryMetrics(ctx context.Context) {
+	history, err := r.client.GetWifiHistory(ctx)
+	if err != nil {
+		return
+	}
+
+	seqs, lost := r.history.advance(history.GetCurrent(), len(history.GetPingDropRate()))
+	if lost > 0 {
+		log.WithField("Lost", lost).Warn("Router history samples overwritten between polls, consider a shorter interval")
+		r.promRouterHistorySamplesLost.Add(float64(lost))
+	}
+	r.ingestHistory(seqs, history.GetPingDropRate(), history.GetPingLatencyMs())
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"net"
+	"reflect"
+	"sort"
+	"strings"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+)
+
+// Maximum Wi-Fi clients exported, 0 disables client metrics
+var routerClientLimit int = 32
+
+func init() {
+	flag.IntVar(&routerClientLimit, "routerClientLimit", routerClientLimit, "Maximum router Wi-Fi clients to export metrics for, 0 to disable")
+}
+
+// Series state for a client seen in the last poll
+type routerClientSeries struct {
+	name    string
+	info    prometheus.Labels
+	rxBytes uint64
+	txBytes uint64
+	rxErrs  uint64
+}
+
+// Requests router clients and updates per-client metrics
+func (r *routerDevice) updateRouterClientMetrics(ctx context.Context) {
+	if routerClientLimit <= 0 {
+		return
+	}
+
+	resp, err := r.client.GetWifiClients(ctx)
+	if err != nil {
+		return
+	}
+
+	// Cap clients in a stable order so series don't churn
+	clients := resp.GetClients()
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].GetMacAddress() < clients[j].GetMacAddress()
+	})
+	r.promRouterClients.Set(float64(len(clients)))
+	if len(clients) > routerClientLimit {
+		log.WithFields(logrus.Fields{"Clients": len(clients), "Limit": routerClientLimit}).
+			Debug("Router client limit reached, ignoring remaining clients")
+		clients = clients[:routerClientLimit]
+	}
+
+	seen := make(map[string]routerClientSeries, len(clients))
+	for _, c := range clients {
+		mac := normalizeMAC(c.GetMacAddress())
+		if mac == "" {
+			continue
+		}
+		last, known := r.clientsSeen[mac]
+		if known && last.name != c.GetName() {
+			// Renamed, replace its series
+			r.deleteRouterClientSeries(mac, last)
+			known = false
+		}
+		seen[mac] = r.updateRouterClient(mac, c, last, known)
+	}
+
+	// Remove series for clients that have left
+	for mac, last := range r.clientsSeen {
+		if _, ok := seen[mac]; !ok {
+			log.WithFields(logrus.Fields{"MAC": mac, "Name": last.name}).Debug("Router client left")
+			r.deleteRouterClientSeries(mac, last)
+		}
+	}
+	r.clientsSeen = seen
+}
+
+// Exports metrics for one client, counters advance from the last poll if known
+func (r *routerDevice) updateRouterClient(mac string, c *starlink.WifiClient, last routerClientSeries, known bool) routerClientSeries {
+	labels := prometheus.Labels{"mac": mac, "name": c.GetName()}
+
+	info := prometheus.Labels{
+		"mac":   mac,
+		"name":  c.GetName(),
+		"ip":    c.GetIpAddress(),
+		"iface": c.GetIface().String(),
+		"role":  c.GetRole().String(),
+	}
+	if known && !reflect.DeepEqual(last.info, info) {
+		r.promRouterClientInfo.Delete(last.info)
+	}
+	r.promRouterClientInfo.With(info).Set(1)
+
+	r.promRouterClientSignalStrength.With(labels).Set(float64(c.GetSignalStrength()))
+	r.promRouterClientSnr.With(labels).Set(float64(c.GetSnr()))
+	r.promRouterClientChannelWidth.With(labels).Set(float64(c.GetChannelWidth()))
+	r.promRouterClientAssociatedTimeS.With(labels).Set(float64(c.GetAssociatedTimeS()))
+	r.promRouterClientRxRateMbps.With(labels).Set(float64(c.GetRxStats().GetRateMbps()))
+	r.promRouterClientTxRateMbps.With(labels).Set(float64(c.GetTxStats().GetRateMbps()))
+
+	current := routerClientSeries{
+		name:    c.GetName(),
+		info:    info,
+		rxBytes: c.GetRxStats().GetBytes(),
+		txBytes: c.GetTxStats().GetBytes(),
+		rxErrs:  c.GetRxStats().GetCountErrors(),
+	}
+	if known {
+		addCounterDelta(r.promRouterClientRxBytes.With(labels), last.rxBytes, current.rxBytes)
+		addCounterDelta(r.promRouterClientTxBytes.With(labels), last.txBytes, current.txBytes)
+		addCounterDelta(r.promRouterClientRxErrors.With(labels), last.rxErrs, current.rxErrs)
+	} else {
+		// Create at zero, totals before we saw the client are unknown
+		r.promRouterClientRxBytes.With(labels)
+		r.promRouterClientTxBytes.With(labels)
+		r.promRouterClientRxErrors.With(labels)
+	}
+	return current
+}
+
+// Deletes every series for a client
+func (r *routerDevice) deleteRouterClientSeries(mac string, last routerClientSeries) {
+	labels := prometheus.Labels{"mac": mac, "name": last.name}
+	r.promRouterClientInfo.Delete(last.info)
+	r.promRouterClientSignalStrength.Delete(labels)
+	r.promRouterClientSnr.Delete(labels)
+	r.promRouterClientChannelWidth.Delete(labels)
+	r.promRouterClientAssociatedTimeS.Delete(labels)
+	r.promRouterClientRxRateMbps.Delete(labels)
+	r.promRouterClientTxRateMbps.Delete(labels)
+	r.promRouterClientRxBytes.Delete(labels)
+	r.promRouterClientTxBytes.Delete(labels)
+	r.promRouterClientRxErrors.Delete(labels)
+}
+
+// Returns a MAC address in lowercase colon form, so client series and
+// configured MACs match however either is written
+func normalizeMAC(mac string) string {
+	mac = strings.TrimSpace(mac)
+	if hw, err := net.ParseMAC(mac); err == nil {
+		return hw.String()
+	}
+	return strings.ToLower(mac)
+}
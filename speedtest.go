@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Speed test settings, scheduling is disabled without a schedule
+var (
+	speedtestSchedule    string
+	speedtestTimeout     string = "2m"
+	speedtestHistory     int    = 20
+	speedtestHistoryFile string // Persists results across restarts if set
+)
+
+func init() {
+	flag.StringVar(&speedtestSchedule, "speedtestSchedule", speedtestSchedule, "Cron expression for scheduled Dishy speed tests, e.g. \"0 */6 * * *\" (disabled if empty)")
+	flag.StringVar(&speedtestTimeout, "speedtestTimeout", speedtestTimeout, "Maximum time to wait for a speed test to complete")
+	flag.IntVar(&speedtestHistory, "speedtestHistory", speedtestHistory, "Number of speed test results kept, on-demand results are also kept until their job expires")
+	flag.StringVar(&speedtestHistoryFile, "speedtestHistoryFile", speedtestHistoryFile, "JSON file to persist speed test results across restarts (disabled if empty)")
+}
+
+// How often a running speed test is polled
+const speedtestPollInterval = 2 * time.Second
+
+// Speed test results
+const (
+	speedtestSuccess = "success"
+	speedtestFailed  = "error"
+	speedtestTimeOut = "timeout"
+)
+
+var errSpeedtestBusy = errors.New("speed test already running")
+
+// A completed speed test
+type speedtestResult struct {
+	ID           uint32    `json:"id,omitempty"`  // Dish test ID
+	Job          string    `json:"job,omitempty"` // On-demand job ID
+	Source       string    `json:"source"`
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	Result       string    `json:"result"`
+	Error        string    `json:"error,omitempty"`
+	DownloadMbps float64   `json:"download_mbps"`
+	UploadMbps   float64   `json:"upload_mbps"`
+	LatencyMs    float64   `json:"latency_ms,omitempty"`
+}
+
+// Recent results, oldest first, and a lock held while any test runs so the
+// dish only runs one at a time
+var (
+	speedtestResults     []speedtestResult
+	speedtestResultsLock sync.RWMutex
+	speedtestRunning     sync.Mutex
+	speedtestMaxWait     time.Duration
+)
+
+// Starts the speed test scheduler if enabled
+func speedtestInit(ctx context.Context) error {
+	var err error
+	if speedtestMaxWait, err = parseDurationFlag("speedtestTimeout", speedtestTimeout); err != nil {
+		return err
+	}
+	if speedtestHistory < 1 {
+		return fmt.Errorf("invalid -speedtestHistory %d, expected at least 1", speedtestHistory)
+	}
+	if err := loadSpeedtestHistory(); err != nil {
+		return err
+	}
+	http.HandleFunc("/speedtest/history", serveSpeedtestHistory)
+	if err := speedtestJobsInit(); err != nil {
+		return err
+	}
+
+	if speedtestSchedule == "" {
+		return nil
+	}
+	schedule, err := parseCron(speedtestSchedule)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for {
+			next := schedule.next(time.Now())
+			if next.IsZero() {
+				log.WithField("Schedule", speedtestSchedule).Error("Speed test schedule never matches")
+				return
+			}
+			log.WithField("Next", next).Debug("Speed test scheduled")
+			timer := time.NewTimer(time.Until(next))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+			runScheduledSpeedtest(ctx)
+		}
+	}()
+
+	return nil
+}
+
+// Runs a scheduled speed test unless Dishy is in an outage or already testing
+func runScheduledSpeedtest(ctx context.Context) {
+	status, err := client.GetStatus(ctx)
+	if err != nil {
+		promDishySpeedtestSkipped.WithLabelValues("unreachable").Inc()
+		return
+	}
+	if status.GetOutage() != nil {
+		log.WithField("Cause", status.GetOutage().GetCause()).Info("Skipping scheduled speed test during outage")
+		promDishySpeedtestSkipped.WithLabelValues("outage").Inc()
+		return
+	}
+
+	if !speedtestRunning.TryLock() {
+		promDishySpeedtestSkipped.WithLabelValues("running").Inc()
+		return
+	}
+	defer speedtestRunning.Unlock()
+	if _, err := measureSpeedtest(ctx, "schedule", "", dishSpeedtest); errors.Is(err, errSpeedtestBusy) {
+		promDishySpeedtestSkipped.WithLabelValues("running").Inc()
+	}
+}
+
+// Measures throughput into a result, filling everything but timing and source
+type speedtestFunc func(ctx context.Context) (speedtestResult, error)
+
+// Runs a speed test and records the result, callers must hold speedtestRunning
+func measureSpeedtest(ctx context.Context, source, job string, test speedtestFunc) (speedtestResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, speedtestMaxWait)
+	defer cancel()
+
+	started := time.Now()
+	result, err := test(ctx)
+	result.Source, result.Job = source, job
+	result.Started, result.Finished = started, time.Now()
+	switch {
+	case errors.Is(err, errSpeedtestBusy):
+		// Started elsewhere, e.g. the app, not our result to record
+		return result, err
+	case errors.Is(err, context.DeadlineExceeded):
+		result.Result, result.Error = speedtestTimeOut, err.Error()
+	case err != nil:
+		result.Result, result.Error = speedtestFailed, err.Error()
+	default:
+		result.Result = speedtestSuccess
+	}
+
+	recordSpeedtest(result)
+	return result, err
+}
+
+// Starts a speed test with StartSpeedtest and polls it to completion
+func dishSpeedtest(ctx context.Context) (speedtestResult, error) {
+	status, err := pollSpeedtest(ctx)
+	if err != nil {
+		return speedtestResult{}, err
+	}
+	result := speedtestResult{
+		ID:           status.GetId(),
+		DownloadMbps: peakMbps(status.GetDown()),
+		UploadMbps:   peakMbps(status.GetUp()),
+	}
+	return result, directionError(status)
+}
+
+// Runs a speed test with the legacy SpeedTest request
+func legacySpeedtest(ctx context.Context) (speedtestResult, error) {
+	resp, err := client.SpeedTest(ctx)
+	if err != nil {
+		return speedtestResult{}, err
+	}
+	// The legacy request only reports through its deprecated fields
+	return speedtestResult{
+		DownloadMbps: float64(resp.GetDownloadMbps()),
+		UploadMbps:   float64(resp.GetUploadMbps()),
+		LatencyMs:    float64(resp.GetLatencyMs()),
+	}, nil
+}
+
+// Starts a speed test and waits for the dish to finish it
+func pollSpeedtest(ctx context.Context) (*starlink.SpeedtestStatus, error) {
+	before, err := client.GetSpeedtestStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if before.GetRunning() {
+		return nil, errSpeedtestBusy
+	}
+	if err := client.StartSpeedtest(ctx); err != nil {
+		return nil, err
+	}
+
+	ticker := time.NewTicker(speedtestPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+
+		// Finished once a new test has stopped running
+		status, err := client.GetSpeedtestStatus(ctx)
+		if err != nil {
+			continue
+		}
+		if !status.GetRunning() && status.GetId() != before.GetId() {
+			return status, nil
+		}
+	}
+}
+
+// Returns the highest throughput sampled in one direction
+func peakMbps(dir *starlink.SpeedtestStatus_Direction) float64 {
+	var peak float32
+	for _, mbps := range dir.GetThroughputsMbps() {
+		if mbps > peak {
+			peak = mbps
+		}
+	}
+	return float64(peak)
+}
+
+// Returns an error if either direction failed
+func directionError(status *starlink.SpeedtestStatus) error {
+	if err := status.GetDown().GetErr(); err != starlink.SpeedtestError_SPEEDTEST_ERROR_NONE {
+		return fmt.Errorf("download: %s", err)
+	}
+	if err := status.GetUp().GetErr(); err != starlink.SpeedtestError_SPEEDTEST_ERROR_NONE {
+		return fmt.Errorf("upload: %s", err)
+	}
+	return nil
+}
+
+// Exports a result and adds it to the history
+func recordSpeedtest(result speedtestResult) {
+	log.WithFields(logrus.Fields{
+		"Source":   result.Source,
+		"Result":   result.Result,
+		"Error":    result.Error,
+		"Download": result.DownloadMbps,
+		"Upload":   result.UploadMbps,
+	}).Info("Speed test finished")
+
+	promDishySpeedtests.WithLabelValues(result.Source, result.Result).Inc()
+	promDishySpeedtestDuration.Observe(result.Finished.Sub(result.Started).Seconds())
+	if result.Result == speedtestSuccess {
+		promDishySpeedtestDownloadMbps.Set(result.DownloadMbps)
+		promDishySpeedtestUploadMbps.Set(result.UploadMbps)
+		if result.LatencyMs > 0 {
+			promDishySpeedtestLatencyMs.Set(result.LatencyMs)
+		}
+		promDishySpeedtestLastSuccess.Set(float64(result.Finished.Unix()))
+	}
+
+	speedtestResultsLock.Lock()
+	defer speedtestResultsLock.Unlock()
+	speedtestResults = append(speedtestResults, result)
+	trimSpeedtestResults()
+	if err := saveSpeedtestHistory(); err != nil {
+		log.WithField("Error", err).Error("Failed to save speed test history")
+	}
+}
+
+// Loads persisted results if configured
+func loadSpeedtestHistory() error {
+	if speedtestHistoryFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(speedtestHistoryFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read speed test history: %w", err)
+	}
+
+	speedtestResultsLock.Lock()
+	defer speedtestResultsLock.Unlock()
+	if err := json.Unmarshal(data, &speedtestResults); err != nil {
+		return fmt.Errorf("unable to parse speed test history %s: %w", speedtestHistoryFile, err)
+	}
+	trimSpeedtestResults()
+	log.WithField("Results", len(speedtestResults)).Info("Loaded speed test history")
+	return nil
+}
+
+// Drops the oldest results beyond speedtestHistory, keeping on-demand results
+// until their jobs expire so scheduled runs can't push them out before they
+// are polled. Callers must hold speedtestResultsLock
+func trimSpeedtestResults() {
+	excess := len(speedtestResults) - speedtestHistory
+	if excess <= 0 {
+		return
+	}
+	kept := speedtestResults[:0]
+	for _, result := range speedtestResults {
+		pollable := result.Job != "" && time.Since(result.Finished) < speedtestJobTTL
+		if excess > 0 && !pollable {
+			excess--
+			continue
+		}
+		kept = append(kept, result)
+	}
+	speedtestResults = kept
+}
+
+// Writes results to the history file if configured, callers must hold speedtestResultsLock
+func saveSpeedtestHistory() error {
+	if speedtestHistoryFile == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(speedtestResults, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := speedtestHistoryFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, speedtestHistoryFile)
+}
+
+// Serves recent speed test results as JSON
+func serveSpeedtestHistory(w http.ResponseWriter, r *http.Request) {
+	speedtestResultsLock.RLock()
+	results := make([]speedtestResult, len(speedtestResults))
+	copy(results, speedtestResults)
+	speedtestResultsLock.RUnlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(results); err != nil {
+		log.WithField("Error", err).Debug("Failed to write speed test history")
+	}
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Bearer token for on-demand speed tests, the endpoint is disabled if empty
+var speedtestToken string = os.Getenv("SPEEDTEST_TOKEN")
+
+func init() {
+	flag.StringVar(&speedtestToken, "speedtestToken", speedtestToken, "Bearer token required by POST /speedtest, requires -speedtestHistoryFile (disabled if empty, defaults to $SPEEDTEST_TOKEN)")
+}
+
+// Finished jobs are forgotten after this long, their results are kept in the
+// history at least as long
+const speedtestJobTTL = time.Hour
+
+// Job states
+const (
+	jobRunning = "running"
+	jobDone    = "done"
+)
+
+// An on-demand speed test
+type speedtestJob struct {
+	ID      string           `json:"id"`
+	State   string           `json:"state"`
+	Method  string           `json:"method,omitempty"`
+	Created time.Time        `json:"created"`
+	Result  *speedtestResult `json:"result,omitempty"`
+}
+
+// On-demand jobs by ID
+var (
+	speedtestJobs     = make(map[string]*speedtestJob)
+	speedtestJobsLock sync.Mutex
+)
+
+// Registers the on-demand speed test endpoints if a token is set, results
+// must be persisted so jobs can be polled across restarts
+func speedtestJobsInit() error {
+	if speedtestToken == "" {
+		return nil
+	}
+	if speedtestHistoryFile == "" {
+		return fmt.Errorf("-speedtestToken requires -speedtestHistoryFile to persist results")
+	}
+	http.HandleFunc("/speedtest", requireToken(serveSpeedtestStart))
+	http.HandleFunc("/speedtest/jobs/", requireToken(serveSpeedtestJob))
+	return nil
+}
+
+// Rejects requests without the speed test bearer token
+func requireToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(token), []byte(speedtestToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// Starts a speed test, POST /speedtest?method=start|legacy
+func serveSpeedtestStart(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	method := r.URL.Query().Get("method")
+	var test speedtestFunc
+	switch method {
+	case "", "start":
+		method, test = "start", dishSpeedtest
+	case "legacy":
+		test = legacySpeedtest
+	default:
+		http.Error(w, "unknown method, expected start or legacy", http.StatusBadRequest)
+		return
+	}
+
+	if !speedtestRunning.TryLock() {
+		http.Error(w, errSpeedtestBusy.Error(), http.StatusConflict)
+		return
+	}
+	job := &speedtestJob{ID: newJobID(), State: jobRunning, Method: method, Created: time.Now()}
+
+	speedtestJobsLock.Lock()
+	for id, j := range speedtestJobs {
+		if j.State == jobDone && time.Since(j.Result.Finished) > speedtestJobTTL {
+			delete(speedtestJobs, id)
+		}
+	}
+	speedtestJobs[job.ID] = job
+	snapshot := *job
+	speedtestJobsLock.Unlock()
+
+	log.WithField("Job", job.ID).Info("Starting on-demand speed test")
+	go func() {
+		defer speedtestRunning.Unlock()
+		// Outlives the request
+		result, err := measureSpeedtest(context.Background(), "api", job.ID, test)
+		if errors.Is(err, errSpeedtestBusy) {
+			result.Result, result.Error = speedtestFailed, err.Error()
+		}
+
+		speedtestJobsLock.Lock()
+		job.State, job.Result = jobDone, &result
+		speedtestJobsLock.Unlock()
+	}()
+
+	w.Header().Set("Location", "/speedtest/jobs/"+job.ID)
+	writeJSON(w, http.StatusAccepted, snapshot)
+}
+
+// Reports a job, GET /speedtest/jobs/<id>
+func serveSpeedtestJob(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/speedtest/jobs/")
+
+	speedtestJobsLock.Lock()
+	job, ok := speedtestJobs[id]
+	var snapshot speedtestJob
+	if ok {
+		snapshot = *job
+	}
+	speedtestJobsLock.Unlock()
+
+	if !ok {
+		// Jobs expire, but their results persist in the history
+		speedtestResultsLock.RLock()
+		for i := range speedtestResults {
+			if speedtestResults[i].Job == id {
+				result := speedtestResults[i]
+				snapshot = speedtestJob{ID: id, State: jobDone, Created: result.Started, Result: &result}
+				ok = true
+			}
+		}
+		speedtestResultsLock.RUnlock()
+	}
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, snapshot)
+}
+
+// Returns a random job ID
+func newJobID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		// Unique enough for a handful of jobs
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithField("Error", err).Debug("Failed to write response")
+	}
+}
@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Track settings, recording is disabled without a file
+var (
+	trackFile      string
+	trackDistance  float64 = 50 // Meters moved before a new point is recorded
+	trackMaxPoints int     = 10000
+)
+
+func init() {
+	flag.StringVar(&trackFile, "trackFile", trackFile, "Record the Dishy position track to this file, served at /track.gpx and /track.kml with -location precision unless -location is off (disabled if empty, requires local location access)")
+	flag.Float64Var(&trackDistance, "trackDistance", trackDistance, "Minimum movement in meters before a new track point is recorded")
+	flag.IntVar(&trackMaxPoints, "trackMaxPoints", trackMaxPoints, "Maximum track points kept, oldest are dropped")
+}
+
+// Track events
+const (
+	trackOutageStart = "outage_start"
+	trackOutageEnd   = "outage_end"
+)
+
+// A recorded position, or an outage annotation if Event is set
+type trackPoint struct {
+	Time     time.Time `json:"time"`
+	Lat      float64   `json:"lat"`
+	Lon      float64   `json:"lon"`
+	Alt      float64   `json:"alt"`
+	SpeedMps *float64  `json:"speed_mps,omitempty"`
+	Event    string    `json:"event,omitempty"`
+	Cause    string    `json:"cause,omitempty"`
+}
+
+// Recorded track, oldest first
+var (
+	track     []trackPoint
+	trackLock sync.RWMutex
+)
+
+// Loads the track file, dropping points beyond trackMaxPoints, and serves it
+// unless location export is off
+func loadTrack() error {
+	if trackFile == "" {
+		return nil
+	}
+	if locationMode != locationOff {
+		http.HandleFunc("/track.gpx", serveTrack)
+		http.HandleFunc("/track.kml", serveTrack)
+	}
+	points, err := readTrack(trackFile)
+	if err != nil {
+		return err
+	}
+
+	trackLock.Lock()
+	defer trackLock.Unlock()
+	track = points
+	if len(track) > trackMaxPoints {
+		track = track[len(track)-trackMaxPoints:]
+		if err := saveTrack(); err != nil {
+			return err
+		}
+	}
+	log.WithFields(logrus.Fields{"File": trackFile, "Points": len(track)}).Info("Loaded position track")
+	return nil
+}
+
+// Reads a JSON lines track file, a missing file is an empty track
+func readTrack(path string) ([]trackPoint, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read track: %w", err)
+	}
+	defer f.Close()
+
+	var points []trackPoint
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		var p trackPoint
+		if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+			return nil, fmt.Errorf("unable to parse track %s line %d: %w", path, line, err)
+		}
+		points = append(points, p)
+	}
+	return points, scanner.Err()
+}
+
+// Rewrites the track file, callers must hold trackLock
+func saveTrack() error {
+	tmp := trackFile + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	for _, p := range track {
+		if err := enc.Encode(p); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, trackFile)
+}
+
+// Adds points to the track and the track file, callers must hold trackLock
+func appendTrack(points ...trackPoint) {
+	track = append(track, points...)
+	if len(track) > trackMaxPoints {
+		// Compact the file along with memory, leaving a tenth of the cap
+		// free so the rewrite happens once per that many points
+		track = track[len(track)-(trackMaxPoints-trackMaxPoints/10):]
+		if err := saveTrack(); err != nil {
+			log.WithField("Error", err).Error("Failed to save position track")
+		}
+		return
+	}
+
+	f, err := os.OpenFile(trackFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		log.WithField("Error", err).Error("Failed to open position track")
+		return
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, p := range points {
+		if err := enc.Encode(p); err != nil {
+			log.WithField("Error", err).Error("Failed to write position track")
+			return
+		}
+	}
+}
+
+// Records a position if it moved at least trackDistance from the last one
+func recordTrackPosition(lla *starlink.LLAPosition) {
+	if trackFile == "" {
+		return
+	}
+	point := trackPoint{Time: time.Now(), Lat: lla.GetLat(), Lon: lla.GetLon(), Alt: lla.GetAlt()}
+	if speed, ok := dishy.telemetrySpeed(); ok {
+		point.SpeedMps = &speed
+	}
+
+	trackLock.Lock()
+	defer trackLock.Unlock()
+	if last, ok := lastTrackPosition(); ok && haversine(last.Lat, last.Lon, point.Lat, point.Lon) < trackDistance {
+		return
+	}
+	appendTrack(point)
+}
+
+// Annotates the track with outages not yet recorded, placed at the last
+// position before each outage started
+func recordTrackOutages(outages []*starlink.DishOutage) {
+	if trackFile == "" {
+		return
+	}
+
+	trackLock.Lock()
+	defer trackLock.Unlock()
+
+	// Outages already in the track, the dish buffer survives exporter restarts
+	var recorded time.Time
+	for i := len(track) - 1; i >= 0; i-- {
+		if track[i].Event == trackOutageStart {
+			recorded = track[i].Time
+			break
+		}
+	}
+
+	var points []trackPoint
+	for _, outage := range outages {
+		start := gpsTime(outage.GetStartTimestampNs())
+		if !start.After(recorded) {
+			continue
+		}
+		pos, ok := trackPositionAt(start)
+		if !ok {
+			// Nowhere to place it
+			continue
+		}
+		end := start.Add(time.Duration(outage.GetDurationNs()))
+		cause := outage.GetCause().String()
+		points = append(points,
+			trackPoint{Time: start, Lat: pos.Lat, Lon: pos.Lon, Alt: pos.Alt, Event: trackOutageStart, Cause: cause},
+			trackPoint{Time: end, Lat: pos.Lat, Lon: pos.Lon, Alt: pos.Alt, Event: trackOutageEnd, Cause: cause},
+		)
+	}
+	if len(points) > 0 {
+		appendTrack(points...)
+	}
+}
+
+// Returns the newest position, callers must hold trackLock
+func lastTrackPosition() (trackPoint, bool) {
+	for i := len(track) - 1; i >= 0; i-- {
+		if track[i].Event == "" {
+			return track[i], true
+		}
+	}
+	return trackPoint{}, false
+}
+
+// Returns the last position recorded at or before t, or the first position
+// if t precedes the track, callers must hold trackLock
+func trackPositionAt(t time.Time) (trackPoint, bool) {
+	var found trackPoint
+	var ok bool
+	for _, p := range track {
+		if p.Event != "" {
+			continue
+		}
+		if ok && p.Time.After(t) {
+			break
+		}
+		found, ok = p, true
+	}
+	return found, ok
+}
+
+// GPS epoch as Unix time, and GPS-UTC leap seconds
+const (
+	gpsEpochUnix   = 315964800
+	gpsLeapSeconds = 18
+)
+
+// Converts a dish GPS timestamp to time
+func gpsTime(ns int64) time.Time {
+	return time.Unix(gpsEpochUnix-gpsLeapSeconds, ns)
+}
+
+// Returns the great-circle distance in meters between two positions
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Serves the track at the exported location precision as GPX or KML
+// depending on the path
+func serveTrack(w http.ResponseWriter, r *http.Request) {
+	trackLock.RLock()
+	points := make([]trackPoint, len(track))
+	copy(points, track)
+	trackLock.RUnlock()
+	for i := range points {
+		points[i].Lat, points[i].Lon, points[i].Alt = exportPosition(points[i].Lat, points[i].Lon, points[i].Alt)
+	}
+
+	var err error
+	switch r.URL.Path {
+	case "/track.gpx":
+		w.Header().Set("Content-Type", "application/gpx+xml")
+		err = writeGPX(w, points)
+	case "/track.kml":
+		w.Header().Set("Content-Type", "application/vnd.google-earth.kml+xml")
+		err = writeKML(w, points)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.WithField("Error", err).Debug("Failed to write position track")
+	}
+}
+
+// Writes the track file to stdout as GPX or KML, for `starlink-exporter track gpx|kml`
+func trackCommand(args []string) error {
+	if trackFile == "" {
+		return fmt.Errorf("-trackFile is required")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: track gpx|kml")
+	}
+	points, err := readTrack(trackFile)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case "gpx":
+		return writeGPX(os.Stdout, points)
+	case "kml":
+		return writeKML(os.Stdout, points)
+	default:
+		return fmt.Errorf("unknown track format %q, expected gpx or kml", args[0])
+	}
+}
+
+// GPX 1.1 document
+type gpxDoc struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Xmlns     string     `xml:"xmlns,attr"`
+	Version   string     `xml:"version,attr"`
+	Creator   string     `xml:"creator,attr"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Segment   []gpxPoint `xml:"trk>trkseg>trkpt"`
+}
+
+type gpxPoint struct {
+	Lat   float64  `xml:"lat,attr"`
+	Lon   float64  `xml:"lon,attr"`
+	Ele   float64  `xml:"ele"`
+	Time  string   `xml:"time"`
+	Name  string   `xml:"name,omitempty"`
+	Desc  string   `xml:"desc,omitempty"`
+	Speed *float64 `xml:"extensions>speed,omitempty"`
+}
+
+// Writes positions as a GPX track and outages as waypoints
+func writeGPX(w io.Writer, points []trackPoint) error {
+	doc := gpxDoc{Xmlns: "http://www.topografix.com/GPX/1/1", Version: "1.1", Creator: "starlink-exporter"}
+	for _, p := range points {
+		gp := gpxPoint{Lat: p.Lat, Lon: p.Lon, Ele: p.Alt, Time: p.Time.UTC().Format(time.RFC3339), Speed: p.SpeedMps}
+		if p.Event != "" {
+			gp.Name, gp.Desc = p.Event, p.Cause
+			doc.Waypoints = append(doc.Waypoints, gp)
+		} else {
+			doc.Segment = append(doc.Segment, gp)
+		}
+	}
+	return writeXML(w, doc)
+}
+
+// KML 2.2 document
+type kmlDoc struct {
+	XMLName    xml.Name       `xml:"kml"`
+	Xmlns      string         `xml:"xmlns,attr"`
+	Name       string         `xml:"Document>name"`
+	Placemarks []kmlPlacemark `xml:"Document>Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string        `xml:"name"`
+	Description string        `xml:"description,omitempty"`
+	TimeStamp   *kmlTimeStamp `xml:",omitempty"`
+	Point       *kmlGeometry  `xml:",omitempty"`
+	LineString  *kmlGeometry  `xml:",omitempty"`
+}
+
+type kmlTimeStamp struct {
+	When string `xml:"when"`
+}
+
+type kmlGeometry struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// Writes positions as a KML line and outages as points
+func writeKML(w io.Writer, points []trackPoint) error {
+	doc := kmlDoc{Xmlns: "http://www.opengis.net/kml/2.2", Name: "Starlink Track"}
+	var line []string
+	for _, p := range points {
+		coords := fmt.Sprintf("%f,%f,%f", p.Lon, p.Lat, p.Alt)
+		if p.Event != "" {
+			doc.Placemarks = append(doc.Placemarks, kmlPlacemark{
+				Name:        p.Event,
+				Description: p.Cause,
+				TimeStamp:   &kmlTimeStamp{When: p.Time.UTC().Format(time.RFC3339)},
+				Point:       &kmlGeometry{Coordinates: coords},
+			})
+			continue
+		}
+		line = append(line, coords)
+	}
+	if len(line) > 0 {
+		path := kmlPlacemark{Name: "Track", LineString: &kmlGeometry{Coordinates: strings.Join(line, " ")}}
+		doc.Placemarks = append([]kmlPlacemark{path}, doc.Placemarks...)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
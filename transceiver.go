@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"math"
+
+	starlink "rdmcguire/starlink-exporter/device"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// List of transceiver faults by field name
+var transceiverFaults = []string{
+	"OverTempModemAsicFault",
+	"OverTempPcbaFault",
+	"DcVoltageFault",
+}
+
+// Requests TransceiverGetStatus and updates transceiver metrics
+func (d *dishDevice) updateTransceiverMetrics(ctx context.Context) {
+	if d.transceiverUnsupported {
+		return
+	}
+
+	status, err := d.client.GetTransceiverStatus(ctx)
+	if isUnsupported(err) {
+		log.WithField("Error", err).Warn("Transceiver status not supported by firmware, disabling transceiver metrics")
+		d.transceiverUnsupported = true
+		d.promDishyTransceiverSupported.Set(0)
+		return
+	} else if err != nil {
+		return
+	}
+	d.promDishyTransceiverSupported.Set(1)
+
+	// State Sets
+	setStateSet(d.promDishyTransceiverModState, starlink.TransceiverModulatorState_name, int32(status.GetModState()))
+	setStateSet(d.promDishyTransceiverDemodState, starlink.TransceiverModulatorState_name, int32(status.GetDemodState()))
+	setStateSet(d.promDishyTransceiverTxState, starlink.TransceiverTxRxState_name, int32(status.GetTxState()))
+	setStateSet(d.promDishyTransceiverRxState, starlink.TransceiverTxRxState_name, int32(status.GetRxState()))
+	setStateSet(d.promDishyTransceiverDishState, starlink.DishState_name, int32(status.GetState()))
+	setStateSet(d.promDishyTransceiverBlankingState, starlink.TransceiverTransmitBlankingState_name, int32(status.GetTransmitBlankingState()))
+
+	// Fault Booleans
+	for _, name := range transceiverFaults {
+		d.promDishyTransceiverFaults.WithLabelValues(name).
+			Set(isAlerting(status.GetFaults(), name))
+	}
+
+	// Temperatures
+	d.promDishyTransceiverModemAsicTemp.Set(float64(status.GetModemAsicTemp()))
+	d.promDishyTransceiverTxIfTemp.Set(float64(status.GetTxIfTemp()))
+}
+
+// Sets a state-set gauge, 1 for the current enum value and 0 for the rest
+func setStateSet(vec *prometheus.GaugeVec, names map[int32]string, current int32) {
+	for value, name := range names {
+		var active float64
+		if value == current {
+			active = 1
+		}
+		vec.WithLabelValues(name).Set(active)
+	}
+}
+
+// Transceiver telemetry is opt-in
+var transceiverTelemetry bool
+
+func init() {
+	flag.BoolVar(&transceiverTelemetry, "transceiverTelemetry", transceiverTelemetry, "Collect transceiver RF telemetry (SNR, RSSI, satellite and cell)")
+}
+
+// Requests TransceiverGetTelemetry and updates RF telemetry metrics
+func (d *dishDevice) updateTelemetryMetrics(ctx context.Context) {
+	if !transceiverTelemetry || d.telemetryUnsupported {
+		return
+	}
+
+	telem, err := d.client.GetTransceiverTelemetry(ctx)
+	if isUnsupported(err) {
+		log.WithField("Error", err).Warn("Transceiver telemetry not supported by firmware, disabling telemetry metrics")
+		d.telemetryUnsupported = true
+		return
+	} else if err != nil {
+		return
+	}
+
+	// Antenna
+	d.promDishyTelemAntennaPitch.Set(float64(telem.GetAntennaPitch()))
+	d.promDishyTelemAntennaRoll.Set(float64(telem.GetAntennaRoll()))
+	d.promDishyTelemAntennaRxTheta.Set(float64(telem.GetAntennaRxTheta()))
+	d.promDishyTelemAntennaTrueHeading.Set(float64(telem.GetAntennaTrueHeading()))
+	d.promDishyTelemAntennaPointingMode.Set(float64(telem.GetAntennaPointingMode()))
+
+	// Signal Quality
+	d.promDishyTelemSnrDb.Set(float64(telem.GetSnrDb()))
+	d.promDishyTelemL1SnrAvgDb.Set(float64(telem.GetL1SnrAvgDb()))
+	d.promDishyTelemL1SnrMinDb.Set(float64(telem.GetL1SnrMinDb()))
+	d.promDishyTelemL1SnrMaxDb.Set(float64(telem.GetL1SnrMaxDb()))
+	d.promDishyTelemWbRssiPeakMagDb.Set(float64(telem.GetWbRssiPeakMagDb()))
+	d.promDishyTelemCeRssiDb.Set(float64(telem.GetCeRssiDb()))
+
+	// Scheduling
+	d.promDishyTelemRxChannel.Set(float64(telem.GetRxChannel()))
+	d.promDishyTelemCellID.Set(float64(telem.GetCurrentCellId()))
+	d.promDishyTelemLmacSatelliteID.Set(float64(telem.GetLmacSatelliteId()))
+	d.promDishyTelemTargetSatelliteID.Set(float64(telem.GetTargetSatelliteId()))
+	d.promDishyTelemSecondsUntilSlotEnd.Set(float64(telem.GetSecondsUntilSlotEnd()))
+	d.promDishyTelemCurrentSecondsOfSchedule.Set(float64(telem.GetCurrentSecondsOfSchedule()))
+	d.promDishyTelemGrantMcs.Set(float64(telem.GetGrantMcs()))
+	d.promDishyTelemGrantSymbolsAvg.Set(float64(telem.GetGrantSymbolsAvg()))
+	d.promDishyTelemDedGrant.Set(float64(telem.GetDedGrant()))
+
+	// Motion
+	d.promDishyTelemEmaVelocity.WithLabelValues("x").Set(telem.GetEmaVelocityX())
+	d.promDishyTelemEmaVelocity.WithLabelValues("y").Set(telem.GetEmaVelocityY())
+	d.promDishyTelemEmaVelocity.WithLabelValues("z").Set(telem.GetEmaVelocityZ())
+
+	// Counters, the dish reports running totals
+	if last := d.telemetryLast; last != nil {
+		addCounterDelta(d.promDishyTelemProactiveSlotChanges, uint64(last.GetMobilityProactiveSlotChange()), uint64(telem.GetMobilityProactiveSlotChange()))
+		addCounterDelta(d.promDishyTelemReactiveSlotChanges, uint64(last.GetMobilityReactiveSlotChange()), uint64(telem.GetMobilityReactiveSlotChange()))
+		addCounterDelta(d.promDishyTelemSyncFailures, uint64(last.GetRfpTotalSynFailed()), uint64(telem.GetRfpTotalSynFailed()))
+		addCounterDelta(d.promDishyTelemOutOfSeq, uint64(last.GetNumOutOfSeq()), uint64(telem.GetNumOutOfSeq()))
+		addCounterDelta(d.promDishyTelemUlmapDrops, uint64(last.GetNumUlmapDrop()), uint64(telem.GetNumUlmapDrop()))
+		addCounterDelta(d.promDishyTelemLabelSwitchFailures, uint64(last.GetSendLabelSwitchToGroundFailedCalls()), uint64(telem.GetSendLabelSwitchToGroundFailedCalls()))
+
+		if last.GetCurrentCellId() != telem.GetCurrentCellId() {
+			d.promDishyTelemCellChanges.Inc()
+		}
+		if last.GetLmacSatelliteId() != telem.GetLmacSatelliteId() {
+			d.promDishyTelemSatelliteChanges.Inc()
+		}
+	}
+	d.telemetryLock.Lock()
+	d.telemetryLast = telem
+	d.telemetryLock.Unlock()
+}
+
+// Returns the speed from the last telemetry EMA velocity, if collected
+func (d *dishDevice) telemetrySpeed() (float64, bool) {
+	d.telemetryLock.Lock()
+	defer d.telemetryLock.Unlock()
+	if d.telemetryLast == nil {
+		return 0, false
+	}
+	x, y, z := d.telemetryLast.GetEmaVelocityX(), d.telemetryLast.GetEmaVelocityY(), d.telemetryLast.GetEmaVelocityZ()
+	return math.Sqrt(x*x + y*y + z*z), true
+}
+
+// Adds the change in a device running total to a counter, a total lower
+// than last time means the device restarted and counted from zero
+func addCounterDelta(counter prometheus.Counter, last, current uint64) {
+	if current >= last {
+		counter.Add(float64(current - last))
+	} else {
+		counter.Add(float64(current))
+	}
+}
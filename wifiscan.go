@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	starlink "rdmcguire/starlink-exporter/device"
+)
+
+// Router neighbor scan interval, 0 disables
+var routerScanInterval string = "10m"
+
+func init() {
+	flag.StringVar(&routerScanInterval, "routerScanInterval", routerScanInterval, "Router Wi-Fi neighbor scan interval, 0 to disable (go time.Duration)")
+}
+
+// Channels considered when recommending a channel per band
+var scanCandidates = map[string][]int32{
+	"2.4GHz": {1, 6, 11},
+	"5GHz":   {36, 40, 44, 48, 149, 153, 157, 161, 165},
+}
+
+// Latest scan report, served over HTTP
+var (
+	scanReport     *wifiScanReport
+	scanReportLock sync.RWMutex
+)
+
+// Summary of a router neighbor scan
+type wifiScanReport struct {
+	Updated time.Time                  `json:"updated"`
+	Bands   map[string]*wifiBandReport `json:"bands"`
+}
+
+// Neighbors and channel recommendation for one band
+type wifiBandReport struct {
+	Channels    []int32                   `json:"channels"` // Channels used by the router
+	Recommended int32                     `json:"recommended"`
+	Neighbors   []wifiNeighbor            `json:"neighbors"`
+	ByChannel   map[int32]*wifiChannelUse `json:"by_channel"`
+}
+
+// Neighboring network seen in a scan
+type wifiNeighbor struct {
+	SSID      string `json:"ssid"`
+	BSSID     string `json:"bssid"`
+	Channel   int32  `json:"channel"`
+	SignalDbm int32  `json:"signal_dbm"`
+	NoiseDbm  int32  `json:"noise_dbm"`
+	PhyMode   string `json:"phy_mode"`
+}
+
+// Neighbor use of one channel
+type wifiChannelUse struct {
+	Networks       int     `json:"networks"`
+	StrongestDbm   int32   `json:"strongest_dbm"`
+	InterferenceMw float64 `json:"interference_mw"` // Neighbor power overlapping this channel
+}
+
+// Starts the periodic neighbor scan if the router is configured
+func wifiScanInit(ctx context.Context) error {
+	if router == nil {
+		return nil
+	}
+	interval, err := parseDurationFlag("routerScanInterval", routerScanInterval)
+	if err != nil {
+		return err
+	}
+	if interval <= 0 {
+		return nil
+	}
+
+	http.HandleFunc("/router/scan", serveWifiScan)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			updateWifiScanMetrics()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Requests router diagnostics and updates neighbor metrics
+func updateWifiScanMetrics() {
+	diag, err := router.GetWifiDiagnostics(context.Background())
+	if err != nil {
+		return
+	}
+	report := newWifiScanReport(diag)
+
+	// Delete series the new scan no longer has, resetting would leave the
+	// vectors empty until they are set again
+	scanReportLock.RLock()
+	last := scanReport
+	scanReportLock.RUnlock()
+	if last != nil {
+		for band, b := range last.Bands {
+			current := report.Bands[band]
+			for channel := range b.ByChannel {
+				if current == nil || current.ByChannel[channel] == nil {
+					ch := strconv.Itoa(int(channel))
+					promRouterNeighborNetworks.DeleteLabelValues(band, ch)
+					promRouterNeighborStrongest.DeleteLabelValues(band, ch)
+				}
+			}
+			if current == nil || current.Recommended <= 0 {
+				promRouterRecommendedChannel.DeleteLabelValues(band)
+			}
+		}
+	}
+
+	for band, b := range report.Bands {
+		for channel, use := range b.ByChannel {
+			ch := strconv.Itoa(int(channel))
+			promRouterNeighborNetworks.WithLabelValues(band, ch).Set(float64(use.Networks))
+			promRouterNeighborStrongest.WithLabelValues(band, ch).Set(float64(use.StrongestDbm))
+		}
+		if b.Recommended > 0 {
+			promRouterRecommendedChannel.WithLabelValues(band).Set(float64(b.Recommended))
+		}
+	}
+
+	scanReportLock.Lock()
+	scanReport = report
+	scanReportLock.Unlock()
+}
+
+// A network by SSID, band and channel
+type wifiNetworkKey struct {
+	ssid    string
+	band    string
+	channel int32
+}
+
+// Summarizes neighbors per band and channel, ignoring the router's own
+// networks. Those are matched on SSID, band and channel, neighbors often keep
+// the same default SSID elsewhere.
+func newWifiScanReport(diag *starlink.WifiGetDiagnosticsResponse) *wifiScanReport {
+	report := &wifiScanReport{Updated: time.Now(), Bands: make(map[string]*wifiBandReport)}
+	bandReport := func(band string) *wifiBandReport {
+		if _, ok := report.Bands[band]; !ok {
+			report.Bands[band] = &wifiBandReport{ByChannel: make(map[int32]*wifiChannelUse)}
+		}
+		return report.Bands[band]
+	}
+
+	// Our own networks
+	own := make(map[wifiNetworkKey]bool)
+	for _, n := range diag.GetWifiNetworks() {
+		band := wifiBand(n.GetBand() == starlink.WifiNetwork_WIFI_5GHZ, int32(n.GetChannel()))
+		own[wifiNetworkKey{ssid: n.GetSsid(), band: band, channel: int32(n.GetChannel())}] = true
+		b := bandReport(band)
+		b.Channels = append(b.Channels, int32(n.GetChannel()))
+	}
+
+	// Neighbors
+	for _, n := range diag.GetNetworkScan().GetNetworks() {
+		if n.GetChannel() <= 0 {
+			continue
+		}
+		band := wifiBand(n.GetSource() == starlink.WifiScanResults_Network_SCAN_5GHZ, n.GetChannel())
+		if own[wifiNetworkKey{ssid: n.GetSsid(), band: band, channel: n.GetChannel()}] {
+			continue
+		}
+		b := bandReport(band)
+		b.Neighbors = append(b.Neighbors, wifiNeighbor{
+			SSID:      n.GetSsid(),
+			BSSID:     n.GetBssid(),
+			Channel:   n.GetChannel(),
+			SignalDbm: n.GetSignalLevelDbm(),
+			NoiseDbm:  n.GetNoiseLevelDbm(),
+			PhyMode:   n.GetPhyModeStr(),
+		})
+		use, ok := b.ByChannel[n.GetChannel()]
+		if !ok {
+			use = &wifiChannelUse{StrongestDbm: n.GetSignalLevelDbm()}
+			b.ByChannel[n.GetChannel()] = use
+		}
+		use.Networks++
+		if n.GetSignalLevelDbm() > use.StrongestDbm {
+			use.StrongestDbm = n.GetSignalLevelDbm()
+		}
+	}
+
+	for band, b := range report.Bands {
+		sort.Slice(b.Neighbors, func(i, j int) bool { return b.Neighbors[i].SignalDbm > b.Neighbors[j].SignalDbm })
+		for channel, use := range b.ByChannel {
+			use.InterferenceMw = channelInterference(band, channel, b.Neighbors)
+		}
+		b.Recommended = recommendChannel(band, b.Neighbors)
+	}
+	return report
+}
+
+// Returns the band name for a network, falling back to the channel number
+func wifiBand(is5GHz bool, channel int32) string {
+	if is5GHz || channel > 14 {
+		return "5GHz"
+	}
+	return "2.4GHz"
+}
+
+// Picks the candidate channel with the least neighbor power overlapping it
+func recommendChannel(band string, neighbors []wifiNeighbor) int32 {
+	var best int32
+	bestPower := math.Inf(1)
+	for _, channel := range scanCandidates[band] {
+		if power := channelInterference(band, channel, neighbors); power < bestPower {
+			best, bestPower = channel, power
+		}
+	}
+	return best
+}
+
+// Sums neighbor power in mW overlapping a channel. 2.4GHz channels are 5MHz
+// apart and 20MHz wide so neighbors within 4 channels overlap, weighted by
+// distance, 5GHz channels don't overlap.
+func channelInterference(band string, channel int32, neighbors []wifiNeighbor) float64 {
+	var power float64
+	for _, n := range neighbors {
+		distance := math.Abs(float64(n.Channel - channel))
+		weight := 0.0
+		if band == "2.4GHz" && distance < 5 {
+			weight = 1 - distance/5
+		} else if distance == 0 {
+			weight = 1
+		}
+		power += weight * math.Pow(10, float64(n.SignalDbm)/10)
+	}
+	return power
+}
+
+// Serves the latest scan report as JSON
+func serveWifiScan(w http.ResponseWriter, r *http.Request) {
+	scanReportLock.RLock()
+	report := scanReport
+	scanReportLock.RUnlock()
+	if report == nil {
+		http.Error(w, "router scan not yet available", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		log.WithField("Error", err).Debug("Failed to write router scan")
+	}
+}